@@ -0,0 +1,188 @@
+package helper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	cfgTY "github.com/mycontroller-org/2mqtt/pkg/types/config"
+	"gopkg.in/yaml.v3"
+)
+
+// top level keys with special merge behavior
+const (
+	keyAdapters = "adapters"
+	keyInclude  = "include"
+)
+
+// LoadConfig reads and parses the configuration file or the configuration directory,
+// environment variable and secret file references are substituted before parsing into the config.
+// adapters from all the files are merged, other sections can be defined only on a single file
+func LoadConfig(cfgFilePath string) (*cfgTY.Config, error) {
+	merged, _, err := MergeConfigFiles(cfgFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &cfgTY.Config{}
+	err = merged.Decode(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// global dry run applied on the adapters, changes detected on reload as adapter changes
+	for index := range cfg.Adapters {
+		cfg.Adapters[index].DryRun = cfg.Adapters[index].DryRun.Merge(cfg.DryRun)
+	}
+	return cfg, nil
+}
+
+// MergeConfigFiles returns the merged root mapping node of the configuration files with the substituted values.
+// nodes keep the line numbers of the source file, the top level values and the adapters are mapped to the source file
+func MergeConfigFiles(cfgFilePath string) (*yaml.Node, map[*yaml.Node]string, error) {
+	files, err := ConfigFiles(cfgFilePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	adapters := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	nodeFiles := make(map[*yaml.Node]string)
+	definedOn := make(map[string]string) // key or adapter name to file
+	for index, file := range files {
+		root, err := readConfigFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		if root == nil { // empty file
+			continue
+		}
+
+		for keyIndex := 0; keyIndex+1 < len(root.Content); keyIndex += 2 {
+			key, value := root.Content[keyIndex].Value, root.Content[keyIndex+1]
+			if key == keyInclude && (index != 0 || isDir(cfgFilePath)) {
+				return nil, nil, fmt.Errorf("%s: include is supported only on the main config file", file)
+			}
+
+			switch {
+			case key == keyAdapters:
+				if value.Kind != yaml.SequenceNode && value.Tag != "!!null" {
+					return nil, nil, fmt.Errorf("%s: line %d: adapters should be a list", file, value.Line)
+				}
+				for _, adapter := range value.Content {
+					name := adapterName(adapter)
+					if previousFile, found := definedOn[keyAdapters+"/"+name]; found && name != "" {
+						return nil, nil, fmt.Errorf("%s: duplicate adapter name [%s], already defined on %s", file, name, previousFile)
+					}
+					definedOn[keyAdapters+"/"+name] = file
+					nodeFiles[adapter] = file
+					adapters.Content = append(adapters.Content, adapter)
+				}
+
+			default:
+				if previousFile, found := definedOn[key]; found {
+					return nil, nil, fmt.Errorf("%s: [%s] already defined on %s", file, key, previousFile)
+				}
+				definedOn[key] = file
+				nodeFiles[value] = file
+				merged.Content = append(merged.Content, root.Content[keyIndex], value)
+			}
+		}
+	}
+	merged.Content = append(merged.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: keyAdapters}, adapters)
+	return merged, nodeFiles, nil
+}
+
+// ConfigFiles returns the configuration files.
+// on a directory, yaml files from the directory sorted by name.
+// on a file, the file and the files matching with "include" patterns, patterns are relative to the file location
+func ConfigFiles(cfgFilePath string) ([]string, error) {
+	if isDir(cfgFilePath) {
+		return yamlFiles(filepath.Join(cfgFilePath, "*"))
+	}
+
+	root, err := readConfigFile(cfgFilePath)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{cfgFilePath}
+	if root == nil {
+		return files, nil
+	}
+
+	includes := struct {
+		Include []string `yaml:"include"`
+	}{}
+	if err = root.Decode(&includes); err != nil {
+		return nil, fmt.Errorf("%s: %w", cfgFilePath, err)
+	}
+	for _, pattern := range includes.Include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(cfgFilePath), pattern)
+		}
+		matches, err := yamlFiles(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", cfgFilePath, err)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// returns the root mapping node with the substituted values, nil on empty file
+func readConfigFile(file string) (*yaml.Node, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	document := &yaml.Node{}
+	if err = yaml.Unmarshal(data, document); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if len(document.Content) == 0 {
+		return nil, nil
+	}
+	if err = cfgTY.Substitute(document); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: line %d: config should be a map", file, root.Line)
+	}
+	return root, nil
+}
+
+// returns the yaml files matching the pattern, sorted by name
+func yamlFiles(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0)
+	for _, match := range matches {
+		extension := strings.ToLower(filepath.Ext(match))
+		if (extension == ".yaml" || extension == ".yml") && !isDir(match) {
+			files = append(files, match)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func adapterName(adapter *yaml.Node) string {
+	for index := 0; index+1 < len(adapter.Content); index += 2 {
+		if adapter.Content[index].Value == "name" {
+			return adapter.Content[index+1].Value
+		}
+	}
+	return ""
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
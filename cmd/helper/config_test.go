@@ -0,0 +1,111 @@
+package helper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mycontroller-org/2mqtt/pkg/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		path     string // relative to the test directory
+		adapters []string
+		err      string
+	}{
+		{
+			name: "directory",
+			files: map[string]string{
+				"00-main.yaml":   "logger:\n  level: debug\n",
+				"20-second.yaml": "adapters:\n  - name: second\n",
+				"10-first.yml":   "adapters:\n  - name: first\n",
+				"notes.txt":      "ignored",
+			},
+			path:     ".",
+			adapters: []string{"first", "second"},
+		},
+		{
+			name: "include",
+			files: map[string]string{
+				"config.yaml":         "include:\n  - conf.d/*.yaml\nadapters:\n  - name: main\n",
+				"conf.d/gateway.yaml": "adapters:\n  - name: gateway\n",
+			},
+			path:     "config.yaml",
+			adapters: []string{"main", "gateway"},
+		},
+		{
+			name: "duplicate_adapter",
+			files: map[string]string{
+				"a.yaml": "adapters:\n  - name: gateway\n",
+				"b.yaml": "adapters:\n  - name: gateway\n",
+			},
+			path: ".",
+			err:  "b.yaml: duplicate adapter name [gateway], already defined on",
+		},
+		{
+			name: "duplicate_section",
+			files: map[string]string{
+				"a.yaml": "logger:\n  level: debug\n",
+				"b.yaml": "logger:\n  level: info\n",
+			},
+			path: ".",
+			err:  "b.yaml: [logger] already defined on",
+		},
+		{
+			name: "nested_include",
+			files: map[string]string{
+				"a.yaml": "include:\n  - b.yaml\n",
+			},
+			path: ".",
+			err:  "a.yaml: include is supported only on the main config file",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range test.files {
+				file := filepath.Join(dir, name)
+				assert.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+				assert.NoError(t, os.WriteFile(file, []byte(content), 0644))
+			}
+
+			cfg, err := LoadConfig(filepath.Join(dir, test.path))
+			if test.err != "" {
+				assert.ErrorContains(t, err, test.err)
+				return
+			}
+			assert.NoError(t, err)
+			names := make([]string, 0)
+			for _, adapterCfg := range cfg.Adapters {
+				names = append(names, adapterCfg.Name)
+			}
+			assert.Equal(t, test.adapters, names)
+		})
+	}
+}
+
+func TestMergeConfigFilesValidate(t *testing.T) {
+	dir := t.TempDir()
+	adapter := "adapters:\n  - name: %s\n    enabled: true\n    provider: raw\n    source:\n      type: serial\n      port: /dev/ttyUSB0\n    mqtt:\n      broker: tcp://127.0.0.1:1883\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.yaml"), []byte(fmt.Sprintf(adapter, "first")), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "b.yaml"), []byte("logger:\n  level: info\n"+fmt.Sprintf(adapter, "second")), 0644))
+
+	root, files, err := MergeConfigFiles(dir)
+	assert.NoError(t, err)
+
+	// duplicate serial port across the files, reported with the file and the line
+	issues := validator.ValidateNode(root, files)
+	assert.Equal(t, []validator.Issue{{
+		File:    filepath.Join(dir, "b.yaml"),
+		Line:    9,
+		Path:    "adapters[1].source.port",
+		Message: "serial port [/dev/ttyUSB0] already used on adapters[0]",
+	}}, issues)
+	assert.Equal(t, filepath.Join(dir, "b.yaml")+": line 9: adapters[1].source.port: serial port [/dev/ttyUSB0] already used on adapters[0]", issues[0].String())
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/mycontroller-org/2mqtt/pkg/version"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 
 	contextTY "github.com/mycontroller-org/2mqtt/pkg/types/context"
 
@@ -15,8 +16,14 @@ import (
 )
 
 // loads logger
-func loadLogger(ctx context.Context, cfg cfgTY.LoggerConfig) (context.Context, *zap.Logger) {
-	logger := loggerUtils.GetLogger(cfg.Mode, cfg.Level, cfg.Encoding, false, 0, cfg.EnableStacktrace)
+func loadLogger(ctx context.Context, cfg cfgTY.LoggerConfig) (context.Context, *zap.Logger, zap.AtomicLevel) {
+	// logger created with debug level and filtered with atomic level, to support the level change on reload
+	level := toAtomicLevel(cfg.Level)
+	logger := loggerUtils.GetLogger(cfg.Mode, "debug", cfg.Encoding, false, 0, cfg.EnableStacktrace)
+	logger = logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &levelFilterCore{Core: core, level: level}
+	}))
+
 	logger.Info("welcome to the 2mqtt adapter server :)")
 	ver := version.Get()
 	logger.Info("server information", zap.Any("version", ver), zap.Any("logger", cfg))
@@ -26,7 +33,37 @@ func loadLogger(ctx context.Context, cfg cfgTY.LoggerConfig) (context.Context, *
 	// to fix this, do `grep -rl "zap\.L()"` and fix those manually.
 	zap.ReplaceGlobals(logger)
 
-	return contextTY.LoggerWithContext(ctx, logger), logger
+	return contextTY.LoggerWithContext(ctx, logger), logger, level
+}
+
+// returns atomic level, falls back to info level on invalid input
+func toAtomicLevel(levelStr string) zap.AtomicLevel {
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	if err := level.UnmarshalText([]byte(levelStr)); err != nil {
+		level.SetLevel(zapcore.InfoLevel)
+	}
+	return level
+}
+
+// levelFilterCore drops the entries below the atomic level
+type levelFilterCore struct {
+	zapcore.Core
+	level zap.AtomicLevel
+}
+
+func (c *levelFilterCore) Enabled(level zapcore.Level) bool {
+	return c.level.Enabled(level) && c.Core.Enabled(level)
+}
+
+func (c *levelFilterCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelFilterCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c *levelFilterCore) Check(entry zapcore.Entry, checkedEntry *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.level.Enabled(entry.Level) {
+		return checkedEntry
+	}
+	return c.Core.Check(entry, checkedEntry)
 }
 
 // load core scheduler
@@ -0,0 +1,120 @@
+package helper
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	defaultWatchInterval = time.Second * 5 // config file watch interval
+)
+
+type ReloadHook struct {
+	logger        *zap.Logger
+	filePath      string
+	watchFile     bool
+	watchInterval time.Duration
+	callbackFunc  func()
+	stopCH        chan struct{}
+}
+
+func NewReloadHook(logger *zap.Logger, filePath string, watchFile bool, watchInterval time.Duration, callbackFunc func()) *ReloadHook {
+	if watchInterval <= 0 {
+		watchInterval = defaultWatchInterval
+	}
+	return &ReloadHook{
+		logger:        logger.Named("reload_hook"),
+		filePath:      filePath,
+		watchFile:     watchFile,
+		watchInterval: watchInterval,
+		callbackFunc:  callbackFunc,
+		stopCH:        make(chan struct{}),
+	}
+}
+
+// Start listens SIGHUP signal and watches the config file, if enabled
+func (rh *ReloadHook) Start() {
+	go rh.handleReloadSignal()
+	if rh.watchFile && rh.filePath != "" {
+		go rh.watchConfigFile()
+	}
+}
+
+// Stop terminates the signal listener and file watcher
+func (rh *ReloadHook) Stop() {
+	close(rh.stopCH)
+}
+
+// handle reload signal
+func (rh *ReloadHook) handleReloadSignal() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+
+	for {
+		select {
+		case <-rh.stopCH:
+			return
+		case sig := <-sigs:
+			rh.logger.Info("reload initiated..", zap.Any("signal", sig))
+			rh.triggerReload()
+		}
+	}
+}
+
+// watches the modified time and size of the config files,
+// on a config directory or with includes, added and removed files are detected too
+func (rh *ReloadHook) watchConfigFile() {
+	lastSignature := rh.filesSignature()
+
+	ticker := time.NewTicker(rh.watchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-rh.stopCH:
+			return
+		case <-ticker.C:
+			signature := rh.filesSignature()
+			if signature == "" || signature == lastSignature {
+				continue
+			}
+			lastSignature = signature
+			rh.logger.Info("config file changed, reload initiated..", zap.String("file", rh.filePath))
+			rh.triggerReload()
+		}
+	}
+}
+
+// returns the name, modified time and size of all the config files
+func (rh *ReloadHook) filesSignature() string {
+	files, err := ConfigFiles(rh.filePath)
+	if err != nil {
+		rh.logger.Error("error on getting config files", zap.String("file", rh.filePath), zap.Error(err))
+		return ""
+	}
+	signature := strings.Builder{}
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			rh.logger.Error("error on getting config file info", zap.String("file", file), zap.Error(err))
+			return ""
+		}
+		fmt.Fprintf(&signature, "%s:%d:%d\n", file, info.ModTime().UnixNano(), info.Size())
+	}
+	return signature.String()
+}
+
+func (rh *ReloadHook) triggerReload() {
+	start := time.Now()
+	if rh.callbackFunc != nil {
+		rh.callbackFunc()
+	}
+	rh.logger.Info("reload completed", zap.String("timeTaken", time.Since(start).String()))
+}
@@ -2,22 +2,41 @@ package helper
 
 import (
 	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	adapterSVC "github.com/mycontroller-org/2mqtt/pkg/service/adapter"
+	httpServer "github.com/mycontroller-org/2mqtt/pkg/service/http_server"
 	"github.com/mycontroller-org/2mqtt/pkg/service/scheduler"
 	"github.com/mycontroller-org/2mqtt/pkg/types/config"
+	contextTY "github.com/mycontroller-org/2mqtt/pkg/types/context"
 	schedulerTY "github.com/mycontroller-org/server/v2/pkg/types/scheduler"
+	"github.com/mycontroller-org/server/v2/pkg/utils"
 	"go.uber.org/zap"
 )
 
+const (
+	heartbeatScheduleID = "scheduler_heartbeat"
+	heartbeatInterval   = time.Second * 10
+)
+
 type ToMqtt struct {
-	ctx    context.Context
-	config *config.Config
-	logger *zap.Logger
+	ConfigFilePath string // used to reload the configuration
+
+	ctx         context.Context
+	config      *config.Config
+	logger      *zap.Logger
+	loggerLevel zap.AtomicLevel
+	reloadMutex sync.Mutex
+	reloadHook  *ReloadHook
 
 	// services
 	coreSchedulerSVC schedulerTY.CoreScheduler // core scheduler, used to execute all the cron jobs
+	httpServerSVC    *httpServer.Server        // serves metrics and health endpoints
+	heartbeat        atomic.Int64              // updated by scheduler, used on liveness check
+	gracePeriod      atomic.Int64              // shutdown grace period of the current config, updated on reload
 }
 
 func (g *ToMqtt) Start(ctx context.Context, cfg *config.Config) error {
@@ -26,7 +45,10 @@ func (g *ToMqtt) Start(ctx context.Context, cfg *config.Config) error {
 	g.ctx = ctx
 
 	// load logger
-	ctx, logger := loadLogger(ctx, cfg.Logger)
+	ctx, logger, loggerLevel := loadLogger(ctx, cfg.Logger)
+
+	// inject config into context
+	ctx = contextTY.ConfigWithContext(ctx, cfg)
 
 	// get core scheduler and inject into context
 	ctx, coreScheduler := loadCoreScheduler(ctx)
@@ -48,8 +70,31 @@ func (g *ToMqtt) Start(ctx context.Context, cfg *config.Config) error {
 	g.ctx = ctx
 	g.config = cfg
 	g.logger = logger
+	g.loggerLevel = loggerLevel
 	g.coreSchedulerSVC = coreScheduler
 
+	// scheduler heartbeat, used to verify the scheduler is running
+	g.heartbeat.Store(time.Now().UnixNano())
+	err = customScheduler.Schedule(heartbeatScheduleID, heartbeatInterval.String(), func() { g.heartbeat.Store(time.Now().UnixNano()) })
+	if err != nil {
+		logger.Error("error on scheduling heartbeat", zap.Error(err))
+		return err
+	}
+
+	// start http server
+	if cfg.HTTPServer.Enabled {
+		httpServerSVC, err := httpServer.New(ctx, cfg.HTTPServer, g.liveness)
+		if err != nil {
+			logger.Error("error on loading http server", zap.Error(err))
+			return err
+		}
+		if err = httpServerSVC.Start(); err != nil {
+			logger.Error("error on starting http server", zap.Error(err))
+			return err
+		}
+		g.httpServerSVC = httpServerSVC
+	}
+
 	// start adapter services
 	err = adapterSVC.Start(ctx, cfg.Adapters)
 	if err != nil {
@@ -59,20 +104,78 @@ func (g *ToMqtt) Start(ctx context.Context, cfg *config.Config) error {
 
 	logger.Info("services are started", zap.String("timeTaken", time.Since(startTime).String()))
 
+	// reload the config on SIGHUP signal or on file change
+	g.reloadHook = NewReloadHook(g.logger, g.ConfigFilePath, cfg.Reload.WatchFile, utils.ToDuration(cfg.Reload.WatchInterval, defaultWatchInterval), g.reload)
+	g.reloadHook.Start()
+
 	// call shutdown hook
-	shutdownHook := NewShutdownHook(g.logger, g.stop)
+	g.gracePeriod.Store(int64(utils.ToDuration(cfg.Shutdown.GracePeriod, defaultGracePeriod)))
+	shutdownHook := NewShutdownHook(g.logger, func() time.Duration { return time.Duration(g.gracePeriod.Load()) }, g.stop)
 	shutdownHook.Start()
 
 	return nil
 }
 
+// liveness returns error, if the scheduler is not running
+func (g *ToMqtt) liveness() error {
+	lastHeartbeat := time.Unix(0, g.heartbeat.Load())
+	if time.Since(lastHeartbeat) > heartbeatInterval*3 {
+		return fmt.Errorf("scheduler is not running, last heartbeat:%s", lastHeartbeat.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// reload loads the config file and applies the changes
+func (g *ToMqtt) reload() {
+	g.reloadMutex.Lock()
+	defer g.reloadMutex.Unlock()
+
+	if g.ConfigFilePath == "" {
+		g.logger.Warn("config file path not set, reload ignored")
+		return
+	}
+
+	cfg, err := LoadConfig(g.ConfigFilePath)
+	if err != nil {
+		g.logger.Error("error on loading config file, keeping the current config", zap.String("file", g.ConfigFilePath), zap.Error(err))
+		return
+	}
+
+	// update logger level
+	if cfg.Logger.Level != g.config.Logger.Level {
+		g.loggerLevel.SetLevel(toAtomicLevel(cfg.Logger.Level).Level())
+		g.logger.Info("logger level updated", zap.String("level", g.loggerLevel.String()))
+	}
+
+	// drain timeout taken from the reloaded config, grace period should follow it
+	g.gracePeriod.Store(int64(utils.ToDuration(cfg.Shutdown.GracePeriod, defaultGracePeriod)))
+
+	ctx := contextTY.ConfigWithContext(g.ctx, cfg)
+	if err = adapterSVC.Reload(ctx, cfg.Adapters); err != nil {
+		g.logger.Error("error on reloading adapter services", zap.Error(err))
+		return
+	}
+	g.ctx = ctx
+	g.config = cfg
+}
+
 func (g *ToMqtt) stop() {
 	// stop services
+	if g.reloadHook != nil {
+		g.reloadHook.Stop()
+	}
 
 	// stop adapter services
 	g.logger.Debug("closing adapter services")
 	adapterSVC.Close()
 
+	if g.httpServerSVC != nil {
+		g.logger.Debug("closing http server")
+		if err := g.httpServerSVC.Close(); err != nil {
+			g.logger.Error("error on closing http server", zap.Error(err))
+		}
+	}
+
 	g.logger.Debug("closing core scheduler")
 	// stop core scheduler
 	if err := g.coreSchedulerSVC.Close(); err != nil {
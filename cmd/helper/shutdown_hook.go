@@ -9,19 +9,21 @@ import (
 	"go.uber.org/zap"
 )
 
-var (
-	graceTerminationPeriod = time.Second * 30 // shutdown grace termination period
+const (
+	defaultGracePeriod = time.Second * 30 // shutdown grace termination period
 )
 
 type ShutdownHook struct {
-	logger       *zap.Logger
-	callbackFunc func()
+	logger          *zap.Logger
+	gracePeriodFunc func() time.Duration // returns the grace period of the current config
+	callbackFunc    func()
 }
 
-func NewShutdownHook(logger *zap.Logger, callbackFunc func()) *ShutdownHook {
+func NewShutdownHook(logger *zap.Logger, gracePeriodFunc func() time.Duration, callbackFunc func()) *ShutdownHook {
 	return &ShutdownHook{
-		logger:       logger.Named("shutdown_hook"),
-		callbackFunc: callbackFunc,
+		logger:          logger.Named("shutdown_hook"),
+		gracePeriodFunc: gracePeriodFunc,
+		callbackFunc:    callbackFunc,
 	}
 }
 
@@ -45,8 +47,16 @@ func (sh *ShutdownHook) handleShutdownSignal() {
 func (sh *ShutdownHook) triggerShutdown() {
 	start := time.Now()
 
+	// grace period taken on shutdown, the config may be reloaded
+	gracePeriod := defaultGracePeriod
+	if sh.gracePeriodFunc != nil {
+		if period := sh.gracePeriodFunc(); period > 0 {
+			gracePeriod = period
+		}
+	}
+
 	// force termination block
-	ticker := time.NewTicker(graceTerminationPeriod)
+	ticker := time.NewTicker(gracePeriod)
 	done := make(chan bool)
 	go func() {
 		for {
@@ -54,7 +64,7 @@ func (sh *ShutdownHook) triggerShutdown() {
 			case <-done:
 				return
 			case <-ticker.C:
-				sh.logger.Warn("some services are not terminating on graceful period. Performing force termination", zap.String("gracePeriod", graceTerminationPeriod.String()))
+				sh.logger.Warn("some services are not terminating on graceful period. Performing force termination", zap.String("gracePeriod", gracePeriod.String()))
 				os.Exit(-1)
 			}
 		}
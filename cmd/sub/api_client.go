@@ -0,0 +1,44 @@
+package sub
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/mycontroller-org/2mqtt/cmd/helper"
+)
+
+// adminAPIRequest returns a request to the admin api of a running instance.
+// address and credentials are taken from the config file, if not supplied
+func adminAPIRequest(method, address, token, path string, body io.Reader) (*http.Request, error) {
+	username, password := "", ""
+	if address == "" || token == "" {
+		cfg, err := helper.LoadConfig(cfgFilePath)
+		if err != nil && address == "" {
+			return nil, fmt.Errorf("error on loading config file: %w", err)
+		}
+		if err == nil {
+			if address == "" {
+				if !cfg.HTTPServer.Enabled || !cfg.HTTPServer.API.Enabled {
+					return nil, fmt.Errorf("http_server.api is not enabled on the config file")
+				}
+				address = toLocalAddress(cfg.HTTPServer.ListenAddress)
+			}
+			if token == "" {
+				token = cfg.HTTPServer.API.Token
+				username, password = cfg.HTTPServer.API.Username, cfg.HTTPServer.API.Password
+			}
+		}
+	}
+
+	request, err := http.NewRequest(method, fmt.Sprintf("http://%s%s", address, path), body)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	} else if username != "" {
+		request.SetBasicAuth(username, password)
+	}
+	return request, nil
+}
@@ -0,0 +1,83 @@
+package sub
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/mycontroller-org/2mqtt/cmd/helper"
+	httpServer "github.com/mycontroller-org/2mqtt/pkg/service/http_server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	healthAddress string
+	healthLive    bool
+	healthTimeout time.Duration
+)
+
+func init() {
+	healthCmd.Flags().StringVar(&healthAddress, "address", "", "http server address, default taken from the config file")
+	healthCmd.Flags().BoolVar(&healthLive, "live", false, "verify liveness, default readiness")
+	healthCmd.Flags().DurationVar(&healthTimeout, "timeout", time.Second*5, "request timeout")
+	rootCmd.AddCommand(healthCmd)
+}
+
+var healthCmd = &cobra.Command{
+	Use:   "health",
+	Short: "Verifies the health of a running instance",
+	Long: `Verifies the health of a running instance, exits with non-zero code if it is not healthy.
+can be used as container health check, http_server should be enabled`,
+	Run: func(cmd *cobra.Command, args []string) {
+		address := healthAddress
+		if address == "" {
+			cfg, err := helper.LoadConfig(cfgFilePath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error on loading config file: %s\n", err)
+				os.Exit(1)
+			}
+			if !cfg.HTTPServer.Enabled {
+				fmt.Fprintln(os.Stderr, "http_server is not enabled on the config file")
+				os.Exit(1)
+			}
+			address = toLocalAddress(cfg.HTTPServer.ListenAddress)
+		}
+
+		path := httpServer.PathReadiness
+		if healthLive {
+			path = httpServer.PathLiveness
+		}
+
+		client := http.Client{Timeout: healthTimeout}
+		response, err := client.Get(fmt.Sprintf("http://%s%s", address, path))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error on calling health endpoint: %s\n", err)
+			os.Exit(1)
+		}
+		defer response.Body.Close()
+
+		body, _ := io.ReadAll(response.Body)
+		fmt.Println(string(body))
+		if response.StatusCode != http.StatusOK {
+			os.Exit(1)
+		}
+	},
+}
+
+// returns loopback address, if the server listens on all the interfaces
+func toLocalAddress(listenAddress string) string {
+	if listenAddress == "" {
+		listenAddress = httpServer.DefaultListenAddress
+	}
+	host, port, err := net.SplitHostPort(listenAddress)
+	if err != nil {
+		return listenAddress
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port)
+}
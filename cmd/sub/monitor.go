@@ -0,0 +1,94 @@
+package sub
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/mycontroller-org/2mqtt/pkg/capture"
+	httpServer "github.com/mycontroller-org/2mqtt/pkg/service/http_server"
+	"github.com/mycontroller-org/2mqtt/pkg/traffic"
+	"github.com/mycontroller-org/server/v2/pkg/json"
+	"github.com/spf13/cobra"
+)
+
+var (
+	monitorAddress   string
+	monitorToken     string
+	monitorAdapter   string
+	monitorDirection string
+	monitorStage     string
+	monitorTopic     string
+	monitorPayload   string
+	monitorRender    string
+	monitorJSON      bool
+)
+
+func init() {
+	monitorCmd.Flags().StringVar(&monitorAddress, "address", "", "http server address, default taken from the config file")
+	monitorCmd.Flags().StringVar(&monitorToken, "token", "", "admin api token, default taken from the config file")
+	monitorCmd.Flags().StringVar(&monitorAdapter, "adapter", "", "adapter name, default all the adapters")
+	monitorCmd.Flags().StringVar(&monitorDirection, "direction", "", "direction, options: to_mqtt, to_source. default both")
+	monitorCmd.Flags().StringVar(&monitorStage, "stage", "", "stage, options: raw, formatted. default both")
+	monitorCmd.Flags().StringVar(&monitorTopic, "topic", "", "mqtt topic pattern, supports wildcards (+, #)")
+	monitorCmd.Flags().StringVar(&monitorPayload, "payload", "", "payload regular expression")
+	monitorCmd.Flags().StringVar(&monitorRender, "render", traffic.RenderAuto, "data rendering, options: auto, text, hex")
+	monitorCmd.Flags().BoolVar(&monitorJSON, "json", false, "prints the records as json lines, can be used with replay command")
+	rootCmd.AddCommand(monitorCmd)
+}
+
+var monitorCmd = &cobra.Command{
+	Use:   "monitor",
+	Short: "Streams the live traffic of a running instance",
+	Long: `Streams the live traffic of a running instance, on both directions.
+connects to the admin api, http_server.api should be enabled`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if monitorRender != traffic.RenderAuto && monitorRender != traffic.RenderText && monitorRender != traffic.RenderHex {
+			exitWithError(fmt.Errorf("unsupported render [%s], options: %s, %s, %s", monitorRender, traffic.RenderAuto, traffic.RenderText, traffic.RenderHex))
+		}
+
+		query := url.Values{}
+		for key, value := range map[string]string{"adapter": monitorAdapter, "direction": monitorDirection, "stage": monitorStage, "topic": monitorTopic, "payload": monitorPayload} {
+			if value != "" {
+				query.Set(key, value)
+			}
+		}
+		request, err := adminAPIRequest(http.MethodGet, monitorAddress, monitorToken, fmt.Sprintf("%s?%s", httpServer.PathAPITraffic, query.Encode()), nil)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			exitWithError(fmt.Errorf("error on connecting to the admin api: %w", err))
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(response.Body)
+			exitWithError(fmt.Errorf("error on connecting to the admin api, status:%s, %s", response.Status, body))
+		}
+
+		fmt.Fprintf(os.Stderr, "connected to %s, waiting for the traffic..\n", request.URL.Host)
+		scanner := bufio.NewScanner(response.Body)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			if monitorJSON {
+				fmt.Println(scanner.Text())
+				continue
+			}
+			record := capture.Record{}
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				fmt.Fprintf(os.Stderr, "error on parsing a record: %s\n", err)
+				continue
+			}
+			fmt.Print(traffic.Render(record, monitorRender))
+		}
+		if err := scanner.Err(); err != nil {
+			exitWithError(fmt.Errorf("error on reading the traffic: %w", err))
+		}
+		fmt.Fprintln(os.Stderr, "connection closed by the server")
+	},
+}
@@ -0,0 +1,229 @@
+package sub
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/mycontroller-org/2mqtt/cmd/helper"
+	"github.com/mycontroller-org/2mqtt/pkg/capture"
+	adapterSVC "github.com/mycontroller-org/2mqtt/pkg/service/adapter"
+	httpServer "github.com/mycontroller-org/2mqtt/pkg/service/http_server"
+	"github.com/mycontroller-org/2mqtt/pkg/types"
+	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
+	contextTY "github.com/mycontroller-org/2mqtt/pkg/types/context"
+	devicePlugin "github.com/mycontroller-org/2mqtt/plugin/device"
+	mqttDevice "github.com/mycontroller-org/2mqtt/plugin/device/mqtt"
+	providerPlugin "github.com/mycontroller-org/2mqtt/plugin/provider"
+	"github.com/mycontroller-org/server/v2/pkg/json"
+	"github.com/mycontroller-org/server/v2/pkg/types/cmap"
+	loggerUtils "github.com/mycontroller-org/server/v2/pkg/utils/logger"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// replay targets
+const (
+	replayTargetSource = "source"
+	replayTargetMqtt   = "mqtt"
+
+	replayAPITimeout = time.Second * 5
+)
+
+// mqtt config keys removed on the replay client, the client only publishes.
+// availability and status belong to the running adapter
+var mqttReplayExcludedKeys = []string{"subscribe", "availability_topic", "payload_online", "payload_offline", types.KeyMqttStatusTopic}
+
+// source config keys of the resources can not be shared with a running adapter
+var sourceResourceKeys = map[string][]string{
+	types.DeviceSerial: {"port"},
+	types.DeviceHTTP:   {"listen_address"},
+}
+
+var (
+	replayFile    string
+	replayAdapter string
+	replayTarget  string
+	replaySpeed   float64
+)
+
+func init() {
+	replayCmd.Flags().StringVar(&replayFile, "file", "", "capture file to be replayed")
+	replayCmd.Flags().StringVar(&replayAdapter, "adapter", "", "adapter name, device details taken from the config file")
+	replayCmd.Flags().StringVar(&replayTarget, "target", replayTargetSource, "replay target, options: source, mqtt")
+	replayCmd.Flags().Float64Var(&replaySpeed, "speed", 1, "timing scale, 1 keeps the original timing, 2 replays twice faster, 0 without delay")
+	_ = replayCmd.MarkFlagRequired("file")
+	_ = replayCmd.MarkFlagRequired("adapter")
+	rootCmd.AddCommand(replayCmd)
+}
+
+var replayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Replays a captured traffic to a source device or mqtt",
+	Long: `Replays a captured traffic to a source device or mqtt.
+source target replays the messages written to the source device ("to_source" direction),
+mqtt target replays the messages published to mqtt ("to_mqtt" direction)`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if replayTarget != replayTargetSource && replayTarget != replayTargetMqtt {
+			return fmt.Errorf("unsupported target [%s], options: %s, %s", replayTarget, replayTargetSource, replayTargetMqtt)
+		}
+
+		cfg, err := helper.LoadConfig(cfgFilePath)
+		if err != nil {
+			return fmt.Errorf("error on loading config file: %w", err)
+		}
+
+		var selectedAdapter *config.AdapterConfig
+		for index := range cfg.Adapters {
+			if cfg.Adapters[index].Name == replayAdapter {
+				selectedAdapter = &cfg.Adapters[index]
+				break
+			}
+		}
+		if selectedAdapter == nil {
+			return fmt.Errorf("adapter [%s] not found on the config file", replayAdapter)
+		}
+
+		allRecords, err := capture.ReadFile(replayFile)
+		if err != nil {
+			return fmt.Errorf("error on reading capture file: %w", err)
+		}
+		direction := "to_source"
+		if replayTarget == replayTargetMqtt {
+			direction = "to_mqtt"
+		}
+		records := make([]capture.Record, 0)
+		for _, record := range allRecords {
+			if record.Stage == capture.StageFormatted && record.Direction == direction {
+				records = append(records, record)
+			}
+		}
+
+		logger := loggerUtils.GetLogger(cfg.Logger.Mode, cfg.Logger.Level, cfg.Logger.Encoding, false, 0, cfg.Logger.EnableStacktrace)
+		ctx := contextTY.LoggerWithContext(context.Background(), logger)
+
+		deviceType := adapterSVC.MqttDeviceName
+		deviceCfg := replayMqttConfig(selectedAdapter.MQTT)
+		deviceID := fmt.Sprintf("%s_replay", selectedAdapter.Name)
+		if replayTarget == replayTargetSource {
+			if err = verifySourceNotInUse(logger, selectedAdapter); err != nil {
+				return err
+			}
+			// providers update the source config
+			if _, err = providerPlugin.Create(ctx, selectedAdapter.Provider, selectedAdapter.Source, selectedAdapter.FormatterScript); err != nil {
+				return fmt.Errorf("error on loading provider: %w", err)
+			}
+			deviceType = selectedAdapter.Source.GetString(types.KeyType)
+			deviceCfg = selectedAdapter.Source
+		}
+
+		device, err := devicePlugin.Create(ctx, deviceType, deviceID, deviceCfg, func(msg *types.Message) {}, func(state *types.State) {})
+		if err != nil {
+			return fmt.Errorf("error on connecting to %s device: %w", replayTarget, err)
+		}
+		defer func() {
+			if err := device.Close(); err != nil {
+				logger.Error("error on closing the device", zap.String("adapterName", selectedAdapter.Name), zap.String("target", replayTarget), zap.Error(err))
+			}
+		}()
+
+		logger.Info("replaying captured messages", zap.String("adapterName", selectedAdapter.Name), zap.String("target", replayTarget), zap.Int("messages", len(records)), zap.Float64("speed", replaySpeed))
+		start := time.Now()
+		err = capture.Replay(records, replaySpeed, func(record capture.Record) error {
+			message := types.NewMessage(record.Data)
+			for key, value := range record.Others {
+				message.Others[key] = value
+			}
+			return device.Write(message)
+		})
+		if err != nil {
+			return err
+		}
+		logger.Info("replay completed", zap.Int("messages", len(records)), zap.String("timeTaken", time.Since(start).String()))
+		return nil
+	},
+}
+
+// replayMqttConfig returns a copy of the mqtt config without the subscriptions, availability and status settings.
+// the broker list items are copied too, those can override the common settings
+func replayMqttConfig(mqttCfg cmap.CustomMap) cmap.CustomMap {
+	replayCfg := make(cmap.CustomMap)
+	for key, value := range mqttCfg {
+		replayCfg[key] = value
+	}
+	for _, key := range mqttReplayExcludedKeys {
+		delete(replayCfg, key)
+	}
+
+	items, ok := replayCfg[mqttDevice.KeyBrokers].([]interface{})
+	if !ok {
+		return replayCfg
+	}
+	brokers := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		var brokerCfg map[string]interface{}
+		switch typedItem := item.(type) {
+		case cmap.CustomMap:
+			brokerCfg = typedItem
+		case map[string]interface{}:
+			brokerCfg = typedItem
+		default:
+			brokers = append(brokers, item)
+			continue
+		}
+		replayBrokerCfg := make(map[string]interface{})
+		for key, value := range brokerCfg {
+			replayBrokerCfg[key] = value
+		}
+		for _, key := range mqttReplayExcludedKeys {
+			delete(replayBrokerCfg, key)
+		}
+		brokers = append(brokers, replayBrokerCfg)
+	}
+	replayCfg[mqttDevice.KeyBrokers] = brokers
+	return replayCfg
+}
+
+// verifySourceNotInUse returns error, if a running adapter uses the serial port or the listen address of the source.
+// running adapters are taken from the admin api of the running instance, warns if it is not reachable
+func verifySourceNotInUse(logger *zap.Logger, adapterCfg *config.AdapterConfig) error {
+	request, err := adminAPIRequest(http.MethodGet, "", "", httpServer.PathAPIAdapters, nil)
+	if err != nil {
+		logger.Warn("running adapters not verified, make sure the source device is not used by a running instance", zap.Error(err))
+		return nil
+	}
+	client := http.Client{Timeout: replayAPITimeout}
+	response, err := client.Do(request)
+	if err != nil {
+		logger.Warn("running adapters not verified, admin api is not reachable", zap.Error(err))
+		return nil
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil || response.StatusCode != http.StatusOK {
+		logger.Warn("running adapters not verified, error on admin api", zap.Int("statusCode", response.StatusCode), zap.Error(err))
+		return nil
+	}
+	adapters := make([]adapterSVC.Info, 0)
+	if err = json.Unmarshal(body, &adapters); err != nil {
+		logger.Warn("running adapters not verified, invalid response from admin api", zap.Error(err))
+		return nil
+	}
+
+	sourceType := adapterCfg.Source.GetString(types.KeyType)
+	for _, adapter := range adapters {
+		if !adapter.Status.Running || adapter.Config.Source.GetString(types.KeyType) != sourceType {
+			continue
+		}
+		for _, key := range sourceResourceKeys[sourceType] {
+			value := adapterCfg.Source.GetString(key)
+			if value != "" && value == adapter.Config.Source.GetString(key) {
+				return fmt.Errorf("source %s [%s] is used by the running adapter [%s], stop the adapter before replaying", key, value, adapter.Config.Name)
+			}
+		}
+	}
+	return nil
+}
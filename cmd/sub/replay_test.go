@@ -0,0 +1,36 @@
+package sub
+
+import (
+	"testing"
+
+	"github.com/mycontroller-org/server/v2/pkg/types/cmap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayMqttConfig(t *testing.T) {
+	mqttCfg := cmap.CustomMap{
+		"broker":             "tcp://127.0.0.1:1883",
+		"publish":            "out",
+		"subscribe":          "in/#",
+		"availability_topic": "adapter/status",
+		"status_topic":       "adapter/source",
+		"brokers": []interface{}{
+			map[string]interface{}{"broker": "tcp://primary:1883", "subscribe": "primary/#", "availability_topic": "primary/status"},
+			"tcp://standby:1883",
+		},
+	}
+
+	replayCfg := replayMqttConfig(mqttCfg)
+	assert.Equal(t, cmap.CustomMap{
+		"broker":  "tcp://127.0.0.1:1883",
+		"publish": "out",
+		"brokers": []interface{}{
+			map[string]interface{}{"broker": "tcp://primary:1883"},
+			"tcp://standby:1883",
+		},
+	}, replayCfg)
+
+	// adapter config is not modified
+	assert.Equal(t, "in/#", mqttCfg.GetString("subscribe"))
+	assert.Equal(t, "primary/#", mqttCfg["brokers"].([]interface{})[0].(map[string]interface{})["subscribe"])
+}
@@ -6,17 +6,14 @@ import (
 	"os"
 
 	"github.com/mycontroller-org/2mqtt/cmd/helper"
-	cfgTY "github.com/mycontroller-org/2mqtt/pkg/types/config"
 	"github.com/mycontroller-org/2mqtt/pkg/version"
 	loggerUtils "github.com/mycontroller-org/server/v2/pkg/utils/logger"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
-	"gopkg.in/yaml.v3"
 )
 
 var (
 	cfgFilePath string
-	cfg         *cfgTY.Config
 )
 
 func init() {
@@ -41,19 +38,14 @@ var rootCmd = &cobra.Command{
 		}
 
 		// load config file
-		d, err := os.ReadFile(cfgFilePath)
+		cfg, err := helper.LoadConfig(cfgFilePath)
 		if err != nil {
-			logger.Fatal("error on reading config file", zap.Error(err))
-		}
-
-		err = yaml.Unmarshal(d, &cfg)
-		if err != nil {
-			logger.Fatal("failed to parse config file", zap.Error(err))
+			logger.Fatal("error on loading config file", zap.Error(err))
 		}
 
 		// start service
 		ctx := context.Background()
-		toMqtt := helper.ToMqtt{}
+		toMqtt := helper.ToMqtt{ConfigFilePath: cfgFilePath}
 		err = toMqtt.Start(ctx, cfg)
 		if err != nil {
 			logger.Fatal("error on starting the service", zap.Error(err))
@@ -76,3 +68,9 @@ var versionCmd = &cobra.Command{
 		fmt.Println(version.Get().String())
 	},
 }
+
+// prints the error and exits with non-zero code
+func exitWithError(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,28 @@
+package sub
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/mycontroller-org/2mqtt/pkg/schema"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+}
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Prints the json schema of the configuration file",
+	Long: `Prints the json schema of the configuration file.
+can be used on the editors for the autocomplete and validation, source properties are selected by the source type`,
+	Run: func(cmd *cobra.Command, args []string) {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(schema.Generate()); err != nil {
+			exitWithError(fmt.Errorf("error on encoding schema: %w", err))
+		}
+	},
+}
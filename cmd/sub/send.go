@@ -0,0 +1,101 @@
+package sub
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	adapterSVC "github.com/mycontroller-org/2mqtt/pkg/service/adapter"
+	httpServer "github.com/mycontroller-org/2mqtt/pkg/service/http_server"
+	"github.com/mycontroller-org/2mqtt/pkg/types"
+	"github.com/mycontroller-org/server/v2/pkg/json"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sendAddress   string
+	sendToken     string
+	sendAdapter   string
+	sendDirection string
+	sendData      string
+	sendHex       bool
+	sendTopic     string
+	sendFormat    bool
+	sendTimeout   time.Duration
+)
+
+func init() {
+	sendCmd.Flags().StringVar(&sendAddress, "address", "", "http server address, default taken from the config file")
+	sendCmd.Flags().StringVar(&sendToken, "token", "", "admin api token, default taken from the config file")
+	sendCmd.Flags().StringVar(&sendAdapter, "adapter", "", "adapter name")
+	sendCmd.Flags().StringVar(&sendDirection, "direction", adapterSVC.DirectionToSource, "direction, options: to_source, to_mqtt")
+	sendCmd.Flags().StringVar(&sendData, "data", "", "message data")
+	sendCmd.Flags().BoolVar(&sendHex, "hex", false, "data is hex encoded, used for the binary data")
+	sendCmd.Flags().StringVar(&sendTopic, "topic", "", "mqtt topic, used by the formatter on to_source and as publish topic on to_mqtt")
+	sendCmd.Flags().BoolVar(&sendFormat, "format", false, "executes the pipelines and the provider formatter, as if received from the other device")
+	sendCmd.Flags().DurationVar(&sendTimeout, "timeout", time.Second*10, "request timeout")
+	_ = sendCmd.MarkFlagRequired("adapter")
+	rootCmd.AddCommand(sendCmd)
+}
+
+var sendCmd = &cobra.Command{
+	Use:   "send",
+	Short: "Sends a message through a running adapter",
+	Long: `Sends a message through a running adapter, to the source device or to mqtt.
+connects to the admin api, http_server.api should be enabled. reports the write result and the message as written`,
+	Example: `  2mqtt send --adapter adapter1 --data "12;1;1;0;2;1"
+  2mqtt send --adapter adapter1 --topic 12/1/1/0/2 --data 1 --format
+  2mqtt send --adapter adapter1 --direction to_mqtt --topic status --data online`,
+	Run: func(cmd *cobra.Command, args []string) {
+		sendRequest := adapterSVC.SendRequest{
+			Direction: sendDirection,
+			Data:      sendData,
+			Hex:       sendHex,
+			Format:    sendFormat,
+			Others:    map[string]interface{}{},
+		}
+		if sendTopic != "" {
+			sendRequest.Others[types.KeyMqttTopic] = sendTopic
+		}
+		body, err := json.Marshal(sendRequest)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		path := fmt.Sprintf("%s/%s/%s", httpServer.PathAPIAdapters, url.PathEscape(sendAdapter), httpServer.ActionSend)
+		request, err := adminAPIRequest(http.MethodPost, sendAddress, sendToken, path, bytes.NewReader(body))
+		if err != nil {
+			exitWithError(err)
+		}
+		request.Header.Set("Content-Type", "application/json")
+
+		client := http.Client{Timeout: sendTimeout}
+		response, err := client.Do(request)
+		if err != nil {
+			exitWithError(fmt.Errorf("error on calling the admin api: %w", err))
+		}
+		defer response.Body.Close()
+
+		responseBody, _ := io.ReadAll(response.Body)
+		if response.StatusCode != http.StatusOK {
+			errorResponse := httpServer.ErrorResponse{}
+			if err = json.Unmarshal(responseBody, &errorResponse); err != nil || errorResponse.Error == "" {
+				errorResponse.Error = fmt.Sprintf("status:%s, %s", response.Status, responseBody)
+			}
+			exitWithError(fmt.Errorf("error on sending the message: %s", errorResponse.Error))
+		}
+
+		result := adapterSVC.SendResult{}
+		if err = json.Unmarshal(responseBody, &result); err != nil {
+			exitWithError(fmt.Errorf("error on parsing the response: %w", err))
+		}
+		status := "written"
+		if result.DryRun {
+			status = "not written, adapter is on dry run"
+		}
+		fmt.Printf("%s: %s\ndata: %q\nothers: %v\n", result.Direction, status, result.Data, result.Others)
+	},
+}
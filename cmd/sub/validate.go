@@ -0,0 +1,38 @@
+package sub
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mycontroller-org/2mqtt/cmd/helper"
+	"github.com/mycontroller-org/2mqtt/pkg/validator"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validates the configuration file",
+	Long: `Validates the configuration file without starting the adapters.
+verifies provider and source compatibility, durations, broker urls, scripts, regular expressions and duplicate resources.
+reports the issues with yaml line numbers, exits with non-zero code if there is an issue`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// files are merged as on the runtime, issues report the source file and the line
+		root, files, err := helper.MergeConfigFiles(cfgFilePath)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		issues := validator.ValidateNode(root, files)
+		for _, issue := range issues {
+			fmt.Fprintln(os.Stderr, issue)
+		}
+		if len(issues) > 0 {
+			exitWithError(fmt.Errorf("found %d issue(s)", len(issues)))
+		}
+		fmt.Printf("%s: configuration is valid\n", cfgFilePath)
+	},
+}
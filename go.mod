@@ -5,6 +5,7 @@ go 1.21
 toolchain go1.21.4
 
 require (
+	github.com/dop251/goja v0.0.0-20240220182346-e401ed450204
 	github.com/eclipse/paho.mqtt.golang v1.4.3
 	github.com/mycontroller-org/server/v2 v2.0.1-0.20240330143153-d3837c02560c
 	github.com/spf13/cobra v1.8.0
@@ -17,7 +18,6 @@ require (
 require (
 	github.com/davecgh/go-spew v1.1.2-0.20180830191138-d8f796af33cc // indirect
 	github.com/dlclark/regexp2 v1.11.0 // indirect
-	github.com/dop251/goja v0.0.0-20240220182346-e401ed450204 // indirect
 	github.com/dop251/goja_nodejs v0.0.0-20240221231712-27eeffc9c235 // indirect
 	github.com/fatih/structs v1.1.0 // indirect
 	github.com/go-sourcemap/sourcemap v2.1.3+incompatible // indirect
@@ -0,0 +1,188 @@
+package capture
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mycontroller-org/server/v2/pkg/json"
+)
+
+// defaults
+const (
+	DefaultMaxSize  = int64(10 * 1024 * 1024) // 10 MiB
+	DefaultMaxFiles = 5
+
+	fileName        = "capture.jsonl"
+	rotatedFileName = "capture.%d.jsonl"
+	maxLineSize     = 16 * 1024 * 1024
+)
+
+// capture stages
+const (
+	StageRaw       = "raw"       // as received from the device, before the formatter
+	StageFormatted = "formatted" // after the formatter, to be written to the other device
+)
+
+// Record of a captured message
+type Record struct {
+	Timestamp time.Time              `json:"timestamp"`
+	Adapter   string                 `json:"adapter"`
+	Direction string                 `json:"direction"`
+	Stage     string                 `json:"stage"`
+	Data      []byte                 `json:"data"`
+	Others    map[string]interface{} `json:"others,omitempty"`
+}
+
+// Config of the capture writer
+type Config struct {
+	Dir      string
+	MaxSize  int64
+	MaxFiles int
+}
+
+// Writer writes the records as json lines, the file will be rotated on reaching the max size
+type Writer struct {
+	config Config
+	mutex  sync.Mutex
+	file   *os.File
+	size   int64
+}
+
+// Open creates the directory and opens the capture file in append mode
+func Open(config Config) (*Writer, error) {
+	if config.MaxSize <= 0 {
+		config.MaxSize = DefaultMaxSize
+	}
+	if config.MaxFiles <= 0 {
+		config.MaxFiles = DefaultMaxFiles
+	}
+	if err := os.MkdirAll(config.Dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	w := &Writer{config: config}
+	if err := w.openFile(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) openFile() error {
+	file, err := os.OpenFile(filepath.Join(w.config.Dir, fileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+// Write appends a record
+func (w *Writer) Write(record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		return os.ErrClosed
+	}
+	if w.size > 0 && w.size+int64(len(data)) > w.config.MaxSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	written, err := w.file.Write(data)
+	w.size += int64(written)
+	return err
+}
+
+// rotate renames the current file as capture.1.jsonl, shifts the older files and removes the files beyond the max files
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+
+	_ = os.Remove(filepath.Join(w.config.Dir, fmt.Sprintf(rotatedFileName, w.config.MaxFiles-1)))
+	for index := w.config.MaxFiles - 2; index >= 1; index-- {
+		_ = os.Rename(filepath.Join(w.config.Dir, fmt.Sprintf(rotatedFileName, index)), filepath.Join(w.config.Dir, fmt.Sprintf(rotatedFileName, index+1)))
+	}
+	currentFile := filepath.Join(w.config.Dir, fileName)
+	if w.config.MaxFiles > 1 {
+		if err := os.Rename(currentFile, filepath.Join(w.config.Dir, fmt.Sprintf(rotatedFileName, 1))); err != nil {
+			return err
+		}
+	} else if err := os.Remove(currentFile); err != nil {
+		return err
+	}
+	return w.openFile()
+}
+
+// Close the capture file
+func (w *Writer) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// ReadFile returns all the records from a capture file
+func ReadFile(filename string) ([]Record, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records := make([]Record, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		record := Record{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("invalid record on line %d: %w", lineNumber, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// Replay calls the write function for each record, keeps the original timing divided by the speed.
+// speed zero replays without delay. stops on the first write error
+func Replay(records []Record, speed float64, writeFunc func(record Record) error) error {
+	var previous time.Time
+	for index, record := range records {
+		if index > 0 && speed > 0 {
+			if delay := record.Timestamp.Sub(previous); delay > 0 {
+				time.Sleep(time.Duration(float64(delay) / speed))
+			}
+		}
+		previous = record.Timestamp
+		if err := writeFunc(record); err != nil {
+			return fmt.Errorf("error on replaying record %d: %w", index+1, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package capture
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteAndRotate(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Open(Config{Dir: dir, MaxSize: 200, MaxFiles: 3})
+	assert.NoError(t, err)
+
+	start := time.Now()
+	for index := 0; index < 10; index++ {
+		err = w.Write(Record{Timestamp: start.Add(time.Duration(index) * time.Second), Adapter: "test", Direction: "to_mqtt", Stage: StageRaw, Data: []byte{byte(index), 0x0a}})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, w.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 3)
+
+	_, err = os.Stat(filepath.Join(dir, "capture.3.jsonl"))
+	assert.True(t, os.IsNotExist(err))
+
+	records, err := ReadFile(filepath.Join(dir, fileName))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, records)
+	last := records[len(records)-1]
+	assert.Equal(t, []byte{9, 0x0a}, last.Data)
+	assert.Equal(t, "to_mqtt", last.Direction)
+}
+
+func TestReplay(t *testing.T) {
+	start := time.Now()
+	records := []Record{
+		{Timestamp: start, Data: []byte("1")},
+		{Timestamp: start.Add(100 * time.Millisecond), Data: []byte("2")},
+		{Timestamp: start.Add(200 * time.Millisecond), Data: []byte("3")},
+	}
+
+	replayed := make([]string, 0)
+	writeFunc := func(record Record) error {
+		replayed = append(replayed, string(record.Data))
+		return nil
+	}
+
+	begin := time.Now()
+	assert.NoError(t, Replay(records, 2, writeFunc))
+	elapsed := time.Since(begin)
+	assert.GreaterOrEqual(t, elapsed, 100*time.Millisecond)
+	assert.Less(t, elapsed, 200*time.Millisecond)
+	assert.Equal(t, []string{"1", "2", "3"}, replayed)
+
+	begin = time.Now()
+	assert.NoError(t, Replay(records, 0, writeFunc))
+	assert.Less(t, time.Since(begin), 50*time.Millisecond)
+}
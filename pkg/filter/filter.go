@@ -0,0 +1,131 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
+)
+
+// filter reasons
+const (
+	ReasonTopicDenied       = "topic_denied"
+	ReasonTopicNotAllowed   = "topic_not_allowed"
+	ReasonPayloadDenied     = "payload_denied"
+	ReasonPayloadNotAllowed = "payload_not_allowed"
+	ReasonTooSmall          = "too_small"
+	ReasonTooLarge          = "too_large"
+)
+
+// Filter allows or denies a message by topic, payload and size.
+// deny rules take precedence, if allow rules defined the message should match any of them
+type Filter struct {
+	allowTopics   []string
+	denyTopics    []string
+	allowPayloads []*regexp.Regexp
+	denyPayloads  []*regexp.Regexp
+	minSize       int
+	maxSize       int
+}
+
+// New returns nil, if there is no rule defined
+func New(cfg config.FilterConfig) (*Filter, error) {
+	if len(cfg.AllowTopics) == 0 && len(cfg.DenyTopics) == 0 && len(cfg.AllowPayloads) == 0 &&
+		len(cfg.DenyPayloads) == 0 && cfg.MinSize <= 0 && cfg.MaxSize <= 0 {
+		return nil, nil
+	}
+
+	f := &Filter{
+		allowTopics: cfg.AllowTopics,
+		denyTopics:  cfg.DenyTopics,
+		minSize:     cfg.MinSize,
+		maxSize:     cfg.MaxSize,
+	}
+	var err error
+	if f.allowPayloads, err = compileAll(cfg.AllowPayloads); err != nil {
+		return nil, err
+	}
+	if f.denyPayloads, err = compileAll(cfg.DenyPayloads); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func compileAll(expressions []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(expressions))
+	for _, expression := range expressions {
+		re, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter payload regex [%s]: %w", expression, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// CheckPayload returns the reason, if the payload is filtered. returns empty on allowed
+func (f *Filter) CheckPayload(payload []byte) string {
+	if f == nil {
+		return ""
+	}
+	if f.minSize > 0 && len(payload) < f.minSize {
+		return ReasonTooSmall
+	}
+	if f.maxSize > 0 && len(payload) > f.maxSize {
+		return ReasonTooLarge
+	}
+	for _, re := range f.denyPayloads {
+		if re.Match(payload) {
+			return ReasonPayloadDenied
+		}
+	}
+	if len(f.allowPayloads) == 0 {
+		return ""
+	}
+	for _, re := range f.allowPayloads {
+		if re.Match(payload) {
+			return ""
+		}
+	}
+	return ReasonPayloadNotAllowed
+}
+
+// CheckTopic returns the reason, if the topic is filtered. returns empty on allowed
+func (f *Filter) CheckTopic(topic string) string {
+	if f == nil {
+		return ""
+	}
+	for _, pattern := range f.denyTopics {
+		if MatchTopic(pattern, topic) {
+			return ReasonTopicDenied
+		}
+	}
+	if len(f.allowTopics) == 0 {
+		return ""
+	}
+	for _, pattern := range f.allowTopics {
+		if MatchTopic(pattern, topic) {
+			return ""
+		}
+	}
+	return ReasonTopicNotAllowed
+}
+
+// MatchTopic matches the topic with mqtt wildcard pattern, "+" matches a level and "#" matches the remaining levels
+func MatchTopic(pattern, topic string) bool {
+	patternLevels := strings.Split(pattern, "/")
+	topicLevels := strings.Split(topic, "/")
+	for index, patternLevel := range patternLevels {
+		if patternLevel == "#" {
+			return true
+		}
+		if index >= len(topicLevels) {
+			return false
+		}
+		if patternLevel != "+" && patternLevel != topicLevels[index] {
+			return false
+		}
+	}
+	return len(patternLevels) == len(topicLevels)
+}
@@ -0,0 +1,59 @@
+package filter
+
+import (
+	"testing"
+
+	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		topic    string
+		expected bool
+	}{
+		{pattern: "in/#", topic: "in/1/2", expected: true},
+		{pattern: "in/#", topic: "in", expected: true},
+		{pattern: "in/+/2", topic: "in/1/2", expected: true},
+		{pattern: "in/+/2", topic: "in/1/3", expected: false},
+		{pattern: "in/+", topic: "in/1/2", expected: false},
+		{pattern: "in/1", topic: "in/1", expected: true},
+		{pattern: "in/1/2", topic: "in/1", expected: false},
+		{pattern: "#", topic: "any/topic", expected: true},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, MatchTopic(test.pattern, test.topic), "pattern:%s, topic:%s", test.pattern, test.topic)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	f, err := New(config.FilterConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, f)
+	assert.Equal(t, "", f.CheckTopic("any"))
+
+	f, err = New(config.FilterConfig{
+		AllowTopics:   []string{"in/#"},
+		DenyTopics:    []string{"in/255/#"},
+		AllowPayloads: []string{`^\d+;`},
+		DenyPayloads:  []string{`;3;0;9;`},
+		MinSize:       2,
+		MaxSize:       20,
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "", f.CheckTopic("in/1/2"))
+	assert.Equal(t, ReasonTopicDenied, f.CheckTopic("in/255/3"))
+	assert.Equal(t, ReasonTopicNotAllowed, f.CheckTopic("out/1"))
+
+	assert.Equal(t, "", f.CheckPayload([]byte("1;1;1;0;0;23.5")))
+	assert.Equal(t, ReasonPayloadDenied, f.CheckPayload([]byte("0;255;3;0;9;log")))
+	assert.Equal(t, ReasonPayloadNotAllowed, f.CheckPayload([]byte("hello")))
+	assert.Equal(t, ReasonTooSmall, f.CheckPayload([]byte("1")))
+	assert.Equal(t, ReasonTooLarge, f.CheckPayload([]byte("1;1;1;0;0;0123456789012345")))
+
+	_, err = New(config.FilterConfig{DenyPayloads: []string{"("}})
+	assert.Error(t, err)
+}
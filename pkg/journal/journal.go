@@ -0,0 +1,476 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fsync policies
+const (
+	FsyncAlways   = "always"
+	FsyncInterval = "interval"
+	FsyncNever    = "never"
+)
+
+// default settings
+const (
+	DefaultSegmentSize   = int64(4 * 1024 * 1024) // 4 MiB
+	DefaultFsyncInterval = time.Second
+
+	segmentExtension = ".seg"
+	ackFileName      = "ack"
+	headerSize       = 16 // sequence(8) + length(4) + crc(4)
+)
+
+// Config of a journal
+type Config struct {
+	Dir           string
+	FsyncPolicy   string
+	FsyncInterval time.Duration
+	SegmentSize   int64
+}
+
+// Record is a journal entry
+type Record struct {
+	Sequence uint64
+	Data     []byte
+}
+
+type segment struct {
+	path     string
+	firstSeq uint64
+	lastSeq  uint64
+}
+
+// Journal is an append-only store, split into segment files.
+// acknowledgement is cumulative, once a sequence is acknowledged all the previous records are considered as done
+type Journal struct {
+	logger     *zap.Logger
+	config     Config
+	mutex      sync.Mutex
+	segments   []*segment
+	active     *os.File
+	activeSize int64
+	lastSeq    uint64
+	ackedSeq   uint64
+	savedAck   uint64
+	dirty      bool
+	stopCH     chan struct{}
+	waitGroup  sync.WaitGroup
+}
+
+// Open loads or creates a journal on the given directory
+func Open(logger *zap.Logger, cfg Config) (*Journal, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("journal directory can not be empty")
+	}
+	if cfg.SegmentSize <= 0 {
+		cfg.SegmentSize = DefaultSegmentSize
+	}
+	if cfg.FsyncInterval <= 0 {
+		cfg.FsyncInterval = DefaultFsyncInterval
+	}
+	switch cfg.FsyncPolicy {
+	case FsyncAlways, FsyncInterval, FsyncNever:
+	case "":
+		cfg.FsyncPolicy = FsyncInterval
+	default:
+		return nil, fmt.Errorf("invalid fsync policy:%s", cfg.FsyncPolicy)
+	}
+
+	if err := os.MkdirAll(cfg.Dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	j := &Journal{
+		logger:   logger.Named("journal"),
+		config:   cfg,
+		segments: make([]*segment, 0),
+		stopCH:   make(chan struct{}),
+	}
+
+	ackedSeq, err := j.readAck()
+	if err != nil {
+		return nil, err
+	}
+	j.ackedSeq = ackedSeq
+	j.savedAck = ackedSeq
+	j.lastSeq = ackedSeq
+
+	if err = j.loadSegments(); err != nil {
+		return nil, err
+	}
+	j.compact()
+
+	if err = j.openActive(); err != nil {
+		return nil, err
+	}
+
+	if cfg.FsyncPolicy != FsyncAlways {
+		j.waitGroup.Add(1)
+		go j.syncLoop()
+	}
+
+	return j, nil
+}
+
+// Append writes the data at the end of the journal and returns the sequence number
+func (j *Journal) Append(data []byte) (uint64, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if j.active == nil {
+		return 0, errors.New("journal is closed")
+	}
+
+	if j.activeSize >= j.config.SegmentSize {
+		if err := j.rollSegment(); err != nil {
+			return 0, err
+		}
+	}
+
+	sequence := j.lastSeq + 1
+	record := make([]byte, headerSize+len(data))
+	binary.BigEndian.PutUint64(record[0:8], sequence)
+	binary.BigEndian.PutUint32(record[8:12], uint32(len(data)))
+	binary.BigEndian.PutUint32(record[12:16], crc32.ChecksumIEEE(data))
+	copy(record[headerSize:], data)
+
+	if _, err := j.active.Write(record); err != nil {
+		return 0, err
+	}
+	if j.config.FsyncPolicy == FsyncAlways {
+		if err := j.active.Sync(); err != nil {
+			return 0, err
+		}
+	}
+
+	j.activeSize += int64(len(record))
+	j.lastSeq = sequence
+	activeSegment := j.segments[len(j.segments)-1]
+	if activeSegment.firstSeq == 0 {
+		activeSegment.firstSeq = sequence
+	}
+	activeSegment.lastSeq = sequence
+
+	return sequence, nil
+}
+
+// Ack marks the sequence and all the previous records as done
+func (j *Journal) Ack(sequence uint64) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if sequence <= j.ackedSeq {
+		return nil
+	}
+	j.ackedSeq = sequence
+	j.dirty = true
+
+	if j.config.FsyncPolicy == FsyncAlways {
+		return j.persistAck()
+	}
+	return nil
+}
+
+// Pending returns the records those are not acknowledged, in order.
+// returns up to the limit records, all the records if the limit is zero
+func (j *Journal) Pending(limit int) ([]Record, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	records := make([]Record, 0)
+	for _, seg := range j.segments {
+		if seg.lastSeq <= j.ackedSeq {
+			continue
+		}
+		_, err := readSegment(seg.path, j.ackedSeq+1, func(record Record) bool {
+			records = append(records, record)
+			return limit <= 0 || len(records) < limit
+		})
+		if err != nil {
+			return nil, err
+		}
+		if limit > 0 && len(records) >= limit {
+			break
+		}
+	}
+	return records, nil
+}
+
+// Acked returns the last acknowledged sequence
+func (j *Journal) Acked() uint64 {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.ackedSeq
+}
+
+// Close syncs and closes the journal
+func (j *Journal) Close() error {
+	j.mutex.Lock()
+	if j.active == nil {
+		j.mutex.Unlock()
+		return nil
+	}
+	close(j.stopCH)
+	j.mutex.Unlock()
+
+	j.waitGroup.Wait()
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	err := j.sync()
+	if closeErr := j.active.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	j.active = nil
+	return err
+}
+
+// syncLoop flushes the active segment and the acknowledgement periodically
+func (j *Journal) syncLoop() {
+	defer j.waitGroup.Done()
+
+	ticker := time.NewTicker(j.config.FsyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-j.stopCH:
+			return
+		case <-ticker.C:
+			j.mutex.Lock()
+			if err := j.sync(); err != nil {
+				j.logger.Error("error on syncing the journal", zap.String("dir", j.config.Dir), zap.Error(err))
+			}
+			j.mutex.Unlock()
+		}
+	}
+}
+
+// sync writes the pending changes to disk, the caller should hold the lock
+func (j *Journal) sync() error {
+	if j.config.FsyncPolicy != FsyncNever && j.active != nil {
+		if err := j.active.Sync(); err != nil {
+			return err
+		}
+	}
+	if j.dirty {
+		return j.persistAck()
+	}
+	return nil
+}
+
+// persistAck stores the acknowledged sequence and removes the completed segments
+func (j *Journal) persistAck() error {
+	ackFile := filepath.Join(j.config.Dir, ackFileName)
+	tmpFile := ackFile + ".tmp"
+
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, j.ackedSeq)
+
+	file, err := os.Create(tmpFile)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if j.config.FsyncPolicy != FsyncNever {
+		if err = file.Sync(); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpFile, ackFile); err != nil {
+		return err
+	}
+
+	j.savedAck = j.ackedSeq
+	j.dirty = false
+	j.compact()
+	return nil
+}
+
+func (j *Journal) readAck() (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(j.config.Dir, ackFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if len(data) != 8 {
+		return 0, fmt.Errorf("invalid ack file on %s", j.config.Dir)
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
+
+// loadSegments reads the existing segments and truncates the incomplete record at the end, if any
+func (j *Journal) loadSegments() error {
+	entries, err := os.ReadDir(j.config.Dir)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0)
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), segmentExtension) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		firstSeq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExtension), 10, 64)
+		if err != nil {
+			j.logger.Warn("ignoring an unknown file", zap.String("dir", j.config.Dir), zap.String("file", name))
+			continue
+		}
+		seg := &segment{path: filepath.Join(j.config.Dir, name), firstSeq: firstSeq}
+		validSize, err := readSegment(seg.path, 0, func(record Record) bool {
+			seg.lastSeq = record.Sequence
+			return true
+		})
+		if err != nil {
+			j.logger.Warn("found a corrupted record, truncating the segment", zap.String("file", seg.path), zap.Int64("validSize", validSize), zap.Error(err))
+			if err = os.Truncate(seg.path, validSize); err != nil {
+				return err
+			}
+		}
+		if seg.lastSeq == 0 {
+			seg.firstSeq = 0
+		}
+		if seg.lastSeq > j.lastSeq {
+			j.lastSeq = seg.lastSeq
+		}
+		j.segments = append(j.segments, seg)
+	}
+	return nil
+}
+
+// compact removes the segments those are completely acknowledged, except the active segment
+func (j *Journal) compact() {
+	retained := make([]*segment, 0, len(j.segments))
+	for index, seg := range j.segments {
+		isLast := index == len(j.segments)-1
+		if !isLast && seg.lastSeq <= j.savedAck {
+			if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+				j.logger.Error("error on removing a segment", zap.String("file", seg.path), zap.Error(err))
+				retained = append(retained, seg)
+			}
+			continue
+		}
+		retained = append(retained, seg)
+	}
+	j.segments = retained
+}
+
+func (j *Journal) openActive() error {
+	if len(j.segments) > 0 {
+		last := j.segments[len(j.segments)-1]
+		info, err := os.Stat(last.path)
+		if err != nil {
+			return err
+		}
+		if info.Size() < j.config.SegmentSize {
+			file, err := os.OpenFile(last.path, os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				return err
+			}
+			j.active = file
+			j.activeSize = info.Size()
+			return nil
+		}
+	}
+	return j.rollSegment()
+}
+
+// rollSegment closes the active segment and opens a new one
+func (j *Journal) rollSegment() error {
+	if j.active != nil {
+		if err := j.active.Sync(); err != nil {
+			return err
+		}
+		if err := j.active.Close(); err != nil {
+			return err
+		}
+	}
+
+	firstSeq := j.lastSeq + 1
+	path := filepath.Join(j.config.Dir, fmt.Sprintf("%020d%s", firstSeq, segmentExtension))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	j.active = file
+	j.activeSize = 0
+	j.segments = append(j.segments, &segment{path: path})
+	j.compact()
+	return nil
+}
+
+// readSegment calls the callback for each valid record from the sequence, until the callback returns false.
+// returns the size of the valid records read
+func readSegment(path string, from uint64, callback func(record Record) bool) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	validSize := int64(0)
+	header := make([]byte, headerSize)
+	for {
+		_, err := io.ReadFull(reader, header)
+		if err == io.EOF {
+			return validSize, nil
+		}
+		if err != nil {
+			return validSize, err
+		}
+		sequence := binary.BigEndian.Uint64(header[0:8])
+		length := binary.BigEndian.Uint32(header[8:12])
+		checksum := binary.BigEndian.Uint32(header[12:16])
+
+		// records before the sequence are skipped without reading the data
+		if sequence < from {
+			if _, err = reader.Discard(int(length)); err != nil {
+				return validSize, err
+			}
+			validSize += int64(headerSize) + int64(length)
+			continue
+		}
+
+		data := make([]byte, length)
+		if _, err = io.ReadFull(reader, data); err != nil {
+			return validSize, err
+		}
+		if crc32.ChecksumIEEE(data) != checksum {
+			return validSize, fmt.Errorf("checksum mismatch on sequence:%d", sequence)
+		}
+		validSize += int64(headerSize) + int64(length)
+		if !callback(Record{Sequence: sequence, Data: data}) {
+			return validSize, nil
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package journal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestJournal(t *testing.T) {
+	tests := []struct {
+		testName        string
+		fsyncPolicy     string
+		segmentSize     int64
+		appendCount     int
+		ackSequence     uint64
+		corruptTail     bool
+		expectedPending []string
+	}{
+		{
+			testName:        "TestReplayAll",
+			fsyncPolicy:     FsyncAlways,
+			appendCount:     3,
+			expectedPending: []string{"msg_1", "msg_2", "msg_3"},
+		},
+		{
+			testName:        "TestReplayUnacknowledged",
+			fsyncPolicy:     FsyncInterval,
+			appendCount:     5,
+			ackSequence:     3,
+			expectedPending: []string{"msg_4", "msg_5"},
+		},
+		{
+			testName:        "TestAllAcknowledged",
+			fsyncPolicy:     FsyncNever,
+			segmentSize:     30,
+			appendCount:     5,
+			ackSequence:     5,
+			expectedPending: []string{},
+		},
+		{
+			testName:        "TestCorruptedTail",
+			fsyncPolicy:     FsyncAlways,
+			appendCount:     2,
+			corruptTail:     true,
+			expectedPending: []string{"msg_1", "msg_2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			cfg := Config{Dir: t.TempDir(), FsyncPolicy: test.fsyncPolicy, SegmentSize: test.segmentSize}
+
+			j, err := Open(zap.NewNop(), cfg)
+			assert.NoError(t, err)
+			for index := 1; index <= test.appendCount; index++ {
+				sequence, err := j.Append([]byte(fmt.Sprintf("msg_%d", index)))
+				assert.NoError(t, err)
+				assert.Equal(t, uint64(index), sequence)
+			}
+			if test.ackSequence > 0 {
+				assert.NoError(t, j.Ack(test.ackSequence))
+			}
+			assert.NoError(t, j.Close())
+
+			if test.corruptTail {
+				segments, err := filepath.Glob(filepath.Join(cfg.Dir, "*"+segmentExtension))
+				assert.NoError(t, err)
+				file, err := os.OpenFile(segments[len(segments)-1], os.O_WRONLY|os.O_APPEND, 0644)
+				assert.NoError(t, err)
+				_, err = file.Write([]byte{0, 0, 1})
+				assert.NoError(t, err)
+				assert.NoError(t, file.Close())
+			}
+
+			// reopen and verify the pending records
+			j, err = Open(zap.NewNop(), cfg)
+			assert.NoError(t, err)
+			records, err := j.Pending(0)
+			assert.NoError(t, err)
+			received := []string{}
+			for _, record := range records {
+				received = append(received, string(record.Data))
+			}
+			assert.Equal(t, test.expectedPending, received)
+			assert.Equal(t, test.ackSequence, j.Acked())
+
+			// limited read returns the oldest records
+			limited, err := j.Pending(1)
+			assert.NoError(t, err)
+			assert.Len(t, limited, len(records[:min(1, len(records))]))
+			if len(records) > 0 {
+				assert.Equal(t, records[0], limited[0])
+			}
+
+			// sequence continues after restart
+			sequence, err := j.Append([]byte("next"))
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(test.appendCount+1), sequence)
+			assert.NoError(t, j.Close())
+
+			if test.ackSequence == uint64(test.appendCount) && test.segmentSize > 0 {
+				// completed segments should be removed
+				segments, err := filepath.Glob(filepath.Join(cfg.Dir, "*"+segmentExtension))
+				assert.NoError(t, err)
+				assert.Len(t, segments, 1)
+			}
+		})
+	}
+}
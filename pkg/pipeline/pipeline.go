@@ -0,0 +1,318 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/mycontroller-org/2mqtt/pkg/filter"
+	"github.com/mycontroller-org/2mqtt/pkg/types"
+	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
+	"github.com/mycontroller-org/server/v2/pkg/json"
+	js "github.com/mycontroller-org/server/v2/pkg/utils/javascript"
+	"go.uber.org/zap"
+)
+
+// stage types
+const (
+	StageScript       = "script"
+	StageRegexReplace = "regex_replace"
+	StageTemplate     = "template"
+	StageJSONExtract  = "json_extract"
+	StageEncoding     = "encoding"
+	StageFilter       = "filter"
+)
+
+// encodings supported on the encoding stage
+const (
+	EncodingText   = "text"
+	EncodingHex    = "hex"
+	EncodingBase64 = "base64"
+)
+
+// keys used on the script input and output, same as the raw provider formatter script
+const (
+	keyRawData = "raw_data"
+	keyData    = "data"
+	keyIgnore  = "ignore"
+
+	scriptTimeout = time.Second * 2
+)
+
+// stage modifies the message, returns nil to drop the message
+type stage interface {
+	Process(message *types.Message) (*types.Message, error)
+}
+
+// Pipeline executes the stages in order
+type Pipeline struct {
+	stages []stage
+	names  []string
+}
+
+// New returns a pipeline, returns nil if there is no stage defined
+func New(logger *zap.Logger, stagesCfg []config.PipelineStage) (*Pipeline, error) {
+	if len(stagesCfg) == 0 {
+		return nil, nil
+	}
+
+	p := &Pipeline{}
+	for index, stageCfg := range stagesCfg {
+		_stage, err := newStage(logger, stageCfg)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline stage %d (%s): %w", index+1, stageCfg.Type, err)
+		}
+		name := stageCfg.Name
+		if name == "" {
+			name = fmt.Sprintf("%d_%s", index+1, stageCfg.Type)
+		}
+		p.stages = append(p.stages, _stage)
+		p.names = append(p.names, name)
+	}
+	return p, nil
+}
+
+func newStage(logger *zap.Logger, cfg config.PipelineStage) (stage, error) {
+	switch cfg.Type {
+	case StageScript:
+		if cfg.Script == "" {
+			return nil, fmt.Errorf("script can not be empty")
+		}
+		return &scriptStage{logger: logger, script: cfg.Script}, nil
+
+	case StageRegexReplace:
+		re, err := regexp.Compile(cfg.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		return &regexReplaceStage{pattern: re, replacement: cfg.Replacement}, nil
+
+	case StageTemplate:
+		tmpl, err := template.New("pipeline").Parse(cfg.Template)
+		if err != nil {
+			return nil, err
+		}
+		return &templateStage{template: tmpl}, nil
+
+	case StageJSONExtract:
+		if cfg.Field == "" {
+			return nil, fmt.Errorf("field can not be empty")
+		}
+		return &jsonExtractStage{field: cfg.Field, othersKey: cfg.OthersKey}, nil
+
+	case StageEncoding:
+		for _, encoding := range []string{cfg.From, cfg.To} {
+			if encoding != EncodingText && encoding != EncodingHex && encoding != EncodingBase64 {
+				return nil, fmt.Errorf("unsupported encoding [%s], options: %s, %s, %s", encoding, EncodingText, EncodingHex, EncodingBase64)
+			}
+		}
+		return &encodingStage{from: cfg.From, to: cfg.To}, nil
+
+	case StageFilter:
+		_filter, err := filter.New(cfg.Filter)
+		if err != nil {
+			return nil, err
+		}
+		return &filterStage{filter: _filter}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported stage type [%s]", cfg.Type)
+	}
+}
+
+// Process runs the message through all the stages.
+// returns nil message if a stage dropped it, error includes the stage name
+func (p *Pipeline) Process(message *types.Message) (*types.Message, error) {
+	if p == nil || message == nil {
+		return message, nil
+	}
+	for index, _stage := range p.stages {
+		processed, err := _stage.Process(message)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline stage [%s]: %w", p.names[index], err)
+		}
+		if processed == nil {
+			return nil, nil
+		}
+		if processed.Others == nil {
+			processed.Others = make(map[string]interface{})
+		}
+		message = processed
+	}
+	return message, nil
+}
+
+// topic of the message, absolute topic takes precedence
+func topicOf(message *types.Message) string {
+	if topic := message.Others.GetString(types.KeyMqttAbsoluteTopic); topic != "" {
+		return topic
+	}
+	return message.Others.GetString(types.KeyMqttTopic)
+}
+
+// script stage, input and output are same as the raw provider formatter script
+type scriptStage struct {
+	logger *zap.Logger
+	script string
+}
+
+func (s *scriptStage) Process(message *types.Message) (*types.Message, error) {
+	input := map[string]interface{}{keyRawData: string(message.Data)}
+	for key, value := range message.Others {
+		input[key] = value
+	}
+	timeout := scriptTimeout
+	response, err := js.Execute(s.logger, s.script, input, &timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	switch typedResponse := response.(type) {
+	case string:
+		message.Data = []byte(typedResponse)
+	case map[string]interface{}:
+		for key, value := range typedResponse {
+			switch key {
+			case keyData:
+				message.Data = []byte(fmt.Sprintf("%v", value))
+			case keyIgnore:
+				if strings.EqualFold(strings.TrimSpace(fmt.Sprintf("%v", value)), "true") {
+					return nil, nil
+				}
+			default:
+				message.Others.Set(key, value, nil)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported script result type %T", response)
+	}
+	return message, nil
+}
+
+// regex replace stage, replacement can refer the groups, example: "$1"
+type regexReplaceStage struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+func (s *regexReplaceStage) Process(message *types.Message) (*types.Message, error) {
+	message.Data = s.pattern.ReplaceAll(message.Data, []byte(s.replacement))
+	return message, nil
+}
+
+// template stage, go template with "Data" (string), "Others" and "Topic"
+type templateStage struct {
+	template *template.Template
+}
+
+func (s *templateStage) Process(message *types.Message) (*types.Message, error) {
+	buffer := &bytes.Buffer{}
+	input := map[string]interface{}{
+		"Data":   string(message.Data),
+		"Others": map[string]interface{}(message.Others),
+		"Topic":  topicOf(message),
+	}
+	if err := s.template.Execute(buffer, input); err != nil {
+		return nil, err
+	}
+	message.Data = buffer.Bytes()
+	return message, nil
+}
+
+// json extract stage, extracts a field with dot separated path.
+// updates the data or keeps it on the others, if others key defined
+type jsonExtractStage struct {
+	field     string
+	othersKey string
+}
+
+func (s *jsonExtractStage) Process(message *types.Message) (*types.Message, error) {
+	var data interface{}
+	if err := json.Unmarshal(message.Data, &data); err != nil {
+		return nil, err
+	}
+
+	value := data
+	for _, key := range strings.Split(s.field, ".") {
+		mapValue, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("field [%s] not found", s.field)
+		}
+		if value, ok = mapValue[key]; !ok {
+			return nil, fmt.Errorf("field [%s] not found", s.field)
+		}
+	}
+
+	if s.othersKey != "" {
+		message.Others.Set(s.othersKey, value, nil)
+		return message, nil
+	}
+
+	switch typedValue := value.(type) {
+	case string:
+		message.Data = []byte(typedValue)
+	default:
+		extracted, err := json.Marshal(typedValue)
+		if err != nil {
+			return nil, err
+		}
+		message.Data = extracted
+	}
+	return message, nil
+}
+
+// encoding stage, converts the data between text, hex and base64
+type encodingStage struct {
+	from string
+	to   string
+}
+
+func (s *encodingStage) Process(message *types.Message) (*types.Message, error) {
+	var raw []byte
+	switch s.from {
+	case EncodingHex:
+		decoded, err := hex.DecodeString(strings.TrimSpace(string(message.Data)))
+		if err != nil {
+			return nil, err
+		}
+		raw = decoded
+	case EncodingBase64:
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(message.Data)))
+		if err != nil {
+			return nil, err
+		}
+		raw = decoded
+	default:
+		raw = message.Data
+	}
+
+	switch s.to {
+	case EncodingHex:
+		message.Data = []byte(hex.EncodeToString(raw))
+	case EncodingBase64:
+		message.Data = []byte(base64.StdEncoding.EncodeToString(raw))
+	default:
+		message.Data = raw
+	}
+	return message, nil
+}
+
+// filter stage, drops the message, if it does not pass the filter
+type filterStage struct {
+	filter *filter.Filter
+}
+
+func (s *filterStage) Process(message *types.Message) (*types.Message, error) {
+	if s.filter.CheckPayload(message.Data) != "" {
+		return nil, nil
+	}
+	if topic := topicOf(message); topic != "" && s.filter.CheckTopic(topic) != "" {
+		return nil, nil
+	}
+	return message, nil
+}
@@ -0,0 +1,108 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/mycontroller-org/2mqtt/pkg/types"
+	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestPipeline(t *testing.T) {
+	tests := []struct {
+		testName       string
+		stages         []config.PipelineStage
+		input          *types.Message
+		expectedData   string
+		expectedOthers map[string]interface{}
+		expectedDrop   bool
+	}{
+		{
+			testName:     "TestRegexReplace",
+			stages:       []config.PipelineStage{{Type: StageRegexReplace, Pattern: `^(\d+);(\d+)`, Replacement: "$2;$1"}},
+			input:        types.NewMessage([]byte("1;2;3")),
+			expectedData: "2;1;3",
+		},
+		{
+			testName:     "TestTemplate",
+			stages:       []config.PipelineStage{{Type: StageTemplate, Template: `{"topic":"{{.Topic}}","value":"{{.Data}}"}`}},
+			input:        &types.Message{Data: []byte("23.5"), Others: map[string]interface{}{types.KeyMqttTopic: "1/1"}},
+			expectedData: `{"topic":"1/1","value":"23.5"}`,
+		},
+		{
+			testName:     "TestJSONExtract",
+			stages:       []config.PipelineStage{{Type: StageJSONExtract, Field: "sensor.value"}},
+			input:        types.NewMessage([]byte(`{"sensor":{"value":"23.5"}}`)),
+			expectedData: "23.5",
+		},
+		{
+			testName:       "TestJSONExtractToOthers",
+			stages:         []config.PipelineStage{{Type: StageJSONExtract, Field: "node", OthersKey: "node_id"}},
+			input:          types.NewMessage([]byte(`{"node":12}`)),
+			expectedData:   `{"node":12}`,
+			expectedOthers: map[string]interface{}{"node_id": float64(12)},
+		},
+		{
+			testName:     "TestEncoding",
+			stages:       []config.PipelineStage{{Type: StageEncoding, From: EncodingHex, To: EncodingBase64}, {Type: StageEncoding, From: EncodingBase64, To: EncodingText}},
+			input:        types.NewMessage([]byte("68656c6c6f")),
+			expectedData: "hello",
+		},
+		{
+			testName:     "TestFilterDrop",
+			stages:       []config.PipelineStage{{Type: StageFilter, Filter: config.FilterConfig{DenyPayloads: []string{"^debug"}}}, {Type: StageRegexReplace, Pattern: ".*", Replacement: "modified"}},
+			input:        types.NewMessage([]byte("debug message")),
+			expectedDrop: true,
+		},
+		{
+			testName:       "TestScript",
+			stages:         []config.PipelineStage{{Type: StageScript, Script: `result={data: raw_data + "_modified", mqtt_topic: "hello"}`}},
+			input:          types.NewMessage([]byte("hello")),
+			expectedData:   "hello_modified",
+			expectedOthers: map[string]interface{}{types.KeyMqttTopic: "hello"},
+		},
+		{
+			testName:     "TestScriptIgnore",
+			stages:       []config.PipelineStage{{Type: StageScript, Script: `result={ignore: true}`}},
+			input:        types.NewMessage([]byte("hello")),
+			expectedDrop: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			p, err := New(zap.NewNop(), test.stages)
+			assert.NoError(t, err)
+
+			output, err := p.Process(test.input)
+			assert.NoError(t, err)
+			if test.expectedDrop {
+				assert.Nil(t, output)
+				return
+			}
+			assert.NotNil(t, output)
+			assert.Equal(t, test.expectedData, string(output.Data))
+			for key, value := range test.expectedOthers {
+				assert.Equal(t, value, output.Others.Get(key))
+			}
+		})
+	}
+}
+
+func TestPipelineErrors(t *testing.T) {
+	p, err := New(zap.NewNop(), nil)
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+
+	_, err = New(zap.NewNop(), []config.PipelineStage{{Type: "unknown"}})
+	assert.Error(t, err)
+
+	_, err = New(zap.NewNop(), []config.PipelineStage{{Type: StageEncoding, From: "text", To: "utf16"}})
+	assert.Error(t, err)
+
+	p, err = New(zap.NewNop(), []config.PipelineStage{{Type: StageJSONExtract, Field: "missing"}})
+	assert.NoError(t, err)
+	_, err = p.Process(types.NewMessage([]byte(`{"node":12}`)))
+	assert.Error(t, err)
+}
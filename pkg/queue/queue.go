@@ -0,0 +1,264 @@
+package queue
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// overflow policies, applied when the queue is full
+const (
+	OverflowBlock      = "block"       // producer waits until there is a space
+	OverflowDropNewest = "drop_newest" // incoming item will be dropped
+	OverflowDropOldest = "drop_oldest" // oldest item will be dropped
+	OverflowCoalesce   = "coalesce"    // replaces the queued item with the same key, if not found drops the oldest
+
+	DefaultCapacity       = 1000
+	DefaultOverflowPolicy = OverflowDropNewest
+)
+
+type entry struct {
+	key  string
+	item interface{}
+}
+
+// Queue is a bounded fifo queue with an overflow policy
+type Queue struct {
+	name     string
+	capacity int
+	policy   string
+	mutex    sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	idle     *sync.Cond
+	items    *list.List
+	signals  []interface{} // control items, delivered before the queued items
+	stopped  bool
+	busy     int // items taken by the consumers and not yet processed
+	workers  sync.WaitGroup
+
+	overflows uint64
+	dropped   uint64
+}
+
+// Stats of a queue
+type Stats struct {
+	Size           int    `json:"size"`
+	Capacity       int    `json:"capacity"`
+	OverflowPolicy string `json:"overflow_policy"`
+	Overflows      uint64 `json:"overflows"`
+	Dropped        uint64 `json:"dropped"`
+}
+
+// New returns a queue, capacity and policy fall back to the defaults
+func New(name string, capacity int, policy string) (*Queue, error) {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	if policy == "" {
+		policy = DefaultOverflowPolicy
+	}
+	switch policy {
+	case OverflowBlock, OverflowDropNewest, OverflowDropOldest, OverflowCoalesce:
+	default:
+		return nil, fmt.Errorf("unsupported queue overflow policy [%s]", policy)
+	}
+
+	q := &Queue{
+		name:     name,
+		capacity: capacity,
+		policy:   policy,
+		items:    list.New(),
+	}
+	q.notEmpty = sync.NewCond(&q.mutex)
+	q.notFull = sync.NewCond(&q.mutex)
+	q.idle = sync.NewCond(&q.mutex)
+	return q, nil
+}
+
+// Name of the queue
+func (q *Queue) Name() string {
+	return q.name
+}
+
+// Policy returns the overflow policy
+func (q *Queue) Policy() string {
+	return q.policy
+}
+
+// Produce adds an item to the queue, key is used on coalesce policy.
+// returns the number of items dropped or replaced because of the overflow
+func (q *Queue) Produce(key string, item interface{}) int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.stopped {
+		q.dropped++
+		return 1
+	}
+
+	if q.items.Len() < q.capacity {
+		q.push(key, item)
+		return 0
+	}
+
+	q.overflows++
+	switch q.policy {
+	case OverflowBlock:
+		for q.items.Len() >= q.capacity && !q.stopped {
+			q.notFull.Wait()
+		}
+		if q.stopped {
+			q.dropped++
+			return 1
+		}
+		q.push(key, item)
+		return 0
+
+	case OverflowDropOldest:
+		q.items.Remove(q.items.Front())
+		q.push(key, item)
+
+	case OverflowCoalesce:
+		if key != "" {
+			for element := q.items.Back(); element != nil; element = element.Prev() {
+				if element.Value.(*entry).key == key {
+					element.Value = &entry{key: key, item: item}
+					q.dropped++
+					return 1
+				}
+			}
+		}
+		q.items.Remove(q.items.Front())
+		q.push(key, item)
+
+	default: // drop newest
+	}
+	q.dropped++
+	return 1
+}
+
+// Signal posts a control item to the consumers, not limited by the capacity and the overflow policy.
+// signals are delivered before the queued items, a signal is ignored if the same signal is pending
+func (q *Queue) Signal(item interface{}) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.stopped {
+		return
+	}
+	for _, signal := range q.signals {
+		if signal == item {
+			return
+		}
+	}
+	q.signals = append(q.signals, item)
+	q.notEmpty.Signal()
+}
+
+func (q *Queue) push(key string, item interface{}) {
+	q.items.PushBack(&entry{key: key, item: item})
+	q.notEmpty.Signal()
+}
+
+// StartConsumers starts the workers, items are delivered in order when the workers count is one
+func (q *Queue) StartConsumers(workers int, consumerFunc func(item interface{})) {
+	for index := 0; index < workers; index++ {
+		q.workers.Add(1)
+		go q.consume(consumerFunc)
+	}
+}
+
+func (q *Queue) consume(consumerFunc func(item interface{})) {
+	defer q.workers.Done()
+	for {
+		q.mutex.Lock()
+		for q.items.Len() == 0 && len(q.signals) == 0 && !q.stopped {
+			q.notEmpty.Wait()
+		}
+		if q.stopped {
+			q.mutex.Unlock()
+			return
+		}
+		var item interface{}
+		if len(q.signals) > 0 {
+			item = q.signals[0]
+			q.signals = q.signals[1:]
+		} else {
+			item = q.items.Remove(q.items.Front()).(*entry).item
+			q.notFull.Signal()
+		}
+		q.busy++
+		q.mutex.Unlock()
+
+		consumerFunc(item)
+
+		q.mutex.Lock()
+		q.busy--
+		if q.busy == 0 && q.items.Len() == 0 && len(q.signals) == 0 {
+			q.idle.Broadcast()
+		}
+		q.mutex.Unlock()
+	}
+}
+
+// Drain waits until all the queued items processed by the consumers.
+// returns false, if the items are not processed within the timeout
+func (q *Queue) Drain(timeout time.Duration) bool {
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		q.mutex.Lock()
+		defer q.mutex.Unlock()
+		for (q.items.Len() > 0 || len(q.signals) > 0 || q.busy > 0) && !q.stopped {
+			q.idle.Wait()
+		}
+	}()
+
+	select {
+	case <-drained:
+		return q.Size() == 0
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Stop stops the consumers and releases the blocked producers, queued items will be discarded
+func (q *Queue) Stop() {
+	q.mutex.Lock()
+	q.stopped = true
+	q.items.Init()
+	q.signals = nil
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+	q.idle.Broadcast()
+	q.mutex.Unlock()
+
+	q.workers.Wait()
+}
+
+// Size returns the number of queued items
+func (q *Queue) Size() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.items.Len()
+}
+
+// Capacity of the queue
+func (q *Queue) Capacity() int {
+	return q.capacity
+}
+
+// Stats returns the current statistics
+func (q *Queue) Stats() Stats {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return Stats{
+		Size:           q.items.Len(),
+		Capacity:       q.capacity,
+		OverflowPolicy: q.policy,
+		Overflows:      q.overflows,
+		Dropped:        q.dropped,
+	}
+}
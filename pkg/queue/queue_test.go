@@ -0,0 +1,153 @@
+package queue
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// drains the queue without consumers
+func drain(q *Queue) []interface{} {
+	items := make([]interface{}, 0)
+	for element := q.items.Front(); element != nil; element = element.Next() {
+		items = append(items, element.Value.(*entry).item)
+	}
+	return items
+}
+
+func TestOverflowPolicies(t *testing.T) {
+	tests := []struct {
+		testName        string
+		policy          string
+		keys            []string
+		expectedItems   []interface{}
+		expectedDropped int
+	}{
+		{
+			testName:        "TestDropNewest",
+			policy:          OverflowDropNewest,
+			keys:            []string{"a", "b", "c", "d"},
+			expectedItems:   []interface{}{0, 1, 2},
+			expectedDropped: 1,
+		},
+		{
+			testName:        "TestDropOldest",
+			policy:          OverflowDropOldest,
+			keys:            []string{"a", "b", "c", "d", "e"},
+			expectedItems:   []interface{}{2, 3, 4},
+			expectedDropped: 2,
+		},
+		{
+			testName:        "TestCoalesce",
+			policy:          OverflowCoalesce,
+			keys:            []string{"a", "b", "c", "b", "d"},
+			expectedItems:   []interface{}{3, 2, 4},
+			expectedDropped: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			q, err := New("test", 3, test.policy)
+			assert.NoError(t, err)
+			dropped := 0
+			for index, key := range test.keys {
+				dropped += q.Produce(key, index)
+			}
+			assert.Equal(t, test.expectedItems, drain(q))
+			assert.Equal(t, test.expectedDropped, dropped)
+			assert.Equal(t, uint64(test.expectedDropped), q.Stats().Overflows)
+		})
+	}
+}
+
+func TestInvalidPolicy(t *testing.T) {
+	_, err := New("test", 3, "unknown")
+	assert.Error(t, err)
+}
+
+func TestBlockAndConsume(t *testing.T) {
+	q, err := New("test", 2, OverflowBlock)
+	assert.NoError(t, err)
+
+	mutex := sync.Mutex{}
+	received := make([]interface{}, 0)
+	release := make(chan struct{})
+
+	q.StartConsumers(1, func(item interface{}) {
+		<-release
+		mutex.Lock()
+		received = append(received, item)
+		mutex.Unlock()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		for index := 0; index < 5; index++ {
+			q.Produce("", index)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("producer should be blocked")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	<-done
+	assert.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(received) == 5
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []interface{}{0, 1, 2, 3, 4}, received)
+	assert.Equal(t, uint64(0), q.Stats().Dropped)
+
+	q.Stop()
+	assert.Equal(t, 1, q.Produce("", 5))
+}
+
+func TestDrain(t *testing.T) {
+	q, err := New("test", 10, OverflowDropNewest)
+	assert.NoError(t, err)
+
+	for index := 0; index < 5; index++ {
+		q.Produce("", index)
+	}
+	// no consumers
+	assert.False(t, q.Drain(20*time.Millisecond))
+
+	q.StartConsumers(1, func(item interface{}) { time.Sleep(5 * time.Millisecond) })
+	assert.True(t, q.Drain(time.Second))
+	assert.Equal(t, 0, q.Size())
+
+	q.Produce("", 5)
+	q.Stop()
+	assert.True(t, q.Drain(20*time.Millisecond))
+}
+
+func TestSignal(t *testing.T) {
+	q, err := New("test", 2, OverflowDropNewest)
+	assert.NoError(t, err)
+
+	q.Produce("", 1)
+	q.Produce("", 2)
+	q.Signal("flush")
+	q.Signal("flush")
+	q.Signal("replay")
+
+	// signals are not limited by the capacity and delivered first
+	assert.Equal(t, 2, q.Size())
+	assert.Equal(t, uint64(0), q.Stats().Overflows)
+	assert.Equal(t, uint64(0), q.Stats().Dropped)
+
+	received := make([]interface{}, 0)
+	q.StartConsumers(1, func(item interface{}) { received = append(received, item) })
+	assert.True(t, q.Drain(time.Second))
+	q.Stop()
+	assert.Equal(t, []interface{}{"flush", "replay", 1, 2}, received)
+}
@@ -0,0 +1,154 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/mycontroller-org/2mqtt/pkg/types"
+	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
+	"github.com/mycontroller-org/2mqtt/plugin/device/ethernet"
+	httpDevice "github.com/mycontroller-org/2mqtt/plugin/device/http"
+	mqttDevice "github.com/mycontroller-org/2mqtt/plugin/device/mqtt"
+	"github.com/mycontroller-org/2mqtt/plugin/device/serial"
+	"github.com/mycontroller-org/2mqtt/plugin/device/simulator"
+	mysensorsV2 "github.com/mycontroller-org/2mqtt/plugin/provider/mysensors_v2"
+	"github.com/mycontroller-org/2mqtt/plugin/provider/raw"
+	"github.com/mycontroller-org/server/v2/pkg/types/cmap"
+)
+
+// Draft is the json schema version of the generated schema
+const Draft = "http://json-schema.org/draft-07/schema#"
+
+// Schema is a json schema node
+type Schema map[string]interface{}
+
+// source device configs, discriminated by the source type
+var sourceConfigs = []struct {
+	deviceType string
+	config     interface{}
+}{
+	{types.DeviceSerial, serial.Config{}},
+	{types.DeviceEthernet, ethernet.Config{}},
+	{types.DeviceHTTP, httpDevice.Config{}},
+	{types.DeviceSimulator, simulator.Config{}},
+}
+
+var (
+	providers     = []interface{}{raw.PluginRaw, mysensorsV2.PluginMySensors}
+	customMapType = reflect.TypeOf(cmap.CustomMap{})
+)
+
+// Generate returns the json schema of the configuration file
+func Generate() Schema {
+	root := generate(reflect.TypeOf(config.Config{}), false)
+	root["$schema"] = Draft
+	root["title"] = "2mqtt configuration"
+
+	// device configs are untyped maps on the adapter config, replaced with the device config structs
+	adapter := root["properties"].(Schema)["adapters"].(Schema)["items"].(Schema)
+	adapterProperties := adapter["properties"].(Schema)
+	adapterProperties["provider"] = Schema{"type": "string", "enum": providers}
+	adapterProperties["source"] = sourceSchema()
+	adapterProperties["mqtt"] = mqttSchema()
+	adapter["required"] = []string{"name", "provider", "source", "mqtt"}
+
+	return root
+}
+
+// sourceSchema returns the source config, properties are selected by the "type" field
+func sourceSchema() Schema {
+	deviceTypes := make([]interface{}, 0)
+	conditions := make([]interface{}, 0)
+	for _, sourceCfg := range sourceConfigs {
+		deviceTypes = append(deviceTypes, sourceCfg.deviceType)
+		conditions = append(conditions, Schema{
+			"if":   Schema{"properties": Schema{types.KeyType: Schema{"const": sourceCfg.deviceType}}},
+			"then": generate(reflect.TypeOf(sourceCfg.config), true),
+		})
+	}
+	return Schema{
+		"type":       "object",
+		"required":   []string{types.KeyType},
+		"properties": Schema{types.KeyType: Schema{"type": "string", "enum": deviceTypes}},
+		"allOf":      conditions,
+	}
+}
+
+// mqttSchema returns the mqtt config, with the multiple brokers support
+func mqttSchema() Schema {
+	broker := generate(reflect.TypeOf(mqttDevice.Config{}), true)
+	mqtt := generate(reflect.TypeOf(mqttDevice.Config{}), true)
+	properties := mqtt["properties"].(Schema)
+	properties[mqttDevice.KeyBrokers] = Schema{
+		"type":  "array",
+		"items": Schema{"anyOf": []interface{}{Schema{"type": "string"}, broker}},
+	}
+	properties[mqttDevice.KeyMode] = Schema{"type": "string", "enum": []interface{}{mqttDevice.ModeFailover, mqttDevice.ModeFanout}}
+	properties[mqttDevice.KeyDedupWindow] = Schema{"type": "string"}
+	properties[types.KeyMqttQoS] = Schema{"type": []string{"integer", "string"}}
+	return mqtt
+}
+
+// generate returns the schema of the type.
+// loose accepts the numbers and booleans as string too, device configs are decoded from untyped maps
+func generate(t reflect.Type, loose bool) Schema {
+	if t == customMapType {
+		return Schema{"type": "object"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return generate(t.Elem(), loose)
+
+	case reflect.Struct:
+		properties := Schema{}
+		for index := 0; index < t.NumField(); index++ {
+			field := t.Field(index)
+			if !field.IsExported() {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			properties[name] = generate(field.Type, loose)
+		}
+		schema := Schema{"type": "object", "properties": properties}
+		if !loose {
+			schema["additionalProperties"] = false
+		}
+		return schema
+
+	case reflect.Slice, reflect.Array:
+		return Schema{"type": "array", "items": generate(t.Elem(), loose)}
+
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": generate(t.Elem(), loose)}
+
+	case reflect.Bool:
+		return typeOf("boolean", loose)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return typeOf("integer", loose)
+
+	case reflect.Float32, reflect.Float64:
+		return typeOf("number", loose)
+
+	case reflect.String:
+		return Schema{"type": "string"}
+
+	default:
+		return Schema{}
+	}
+}
+
+func typeOf(name string, loose bool) Schema {
+	if loose {
+		return Schema{"type": []string{name, "string"}}
+	}
+	return Schema{"type": name}
+}
@@ -0,0 +1,32 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	root := Generate()
+	assert.Equal(t, Draft, root["$schema"])
+
+	adapter := root["properties"].(Schema)["adapters"].(Schema)["items"].(Schema)
+	properties := adapter["properties"].(Schema)
+	assert.Equal(t, Schema{"type": "string"}, properties["name"])
+	assert.Equal(t, false, adapter["additionalProperties"])
+
+	// source type discriminator
+	source := properties["source"].(Schema)
+	conditions := source["allOf"].([]interface{})
+	assert.Len(t, conditions, len(sourceConfigs))
+	serial := conditions[0].(Schema)
+	assert.Equal(t, Schema{"properties": Schema{"type": Schema{"const": "serial"}}}, serial["if"])
+	serialProperties := serial["then"].(Schema)["properties"].(Schema)
+	assert.Equal(t, Schema{"type": []string{"integer", "string"}}, serialProperties["baud_rate"])
+	assert.Equal(t, Schema{"type": []string{"integer", "string"}}, serialProperties["message_splitter"])
+
+	// mqtt
+	mqttProperties := properties["mqtt"].(Schema)["properties"].(Schema)
+	assert.Equal(t, Schema{"type": "string"}, mqttProperties["broker"])
+	assert.Contains(t, mqttProperties, "brokers")
+}
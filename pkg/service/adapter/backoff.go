@@ -0,0 +1,107 @@
+package adapter
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
+	"github.com/mycontroller-org/server/v2/pkg/utils"
+)
+
+// minimum reconnect delay, supported by the scheduler
+const minReconnectDelay = time.Second
+
+// backoff calculates the reconnect delay, grows exponentially with jitter
+type backoff struct {
+	mutex        sync.Mutex
+	initialDelay time.Duration
+	maxDelay     time.Duration
+	multiplier   float64
+	jitter       float64
+	maxAttempts  int
+	attempts     int
+	nextRetry    time.Time
+}
+
+// newBackoff returns a backoff, falls back to the fixed delay when the policy is not configured
+func newBackoff(cfg config.ReconnectPolicy, defaultDelay time.Duration) *backoff {
+	b := &backoff{
+		initialDelay: utils.ToDuration(cfg.InitialDelay, defaultDelay),
+		multiplier:   cfg.Multiplier,
+		jitter:       cfg.Jitter,
+		maxAttempts:  cfg.MaxAttempts,
+	}
+	if b.initialDelay < minReconnectDelay {
+		b.initialDelay = minReconnectDelay
+	}
+	if b.multiplier < 1 {
+		b.multiplier = 1
+	}
+	if b.jitter < 0 {
+		b.jitter = 0
+	} else if b.jitter > 1 {
+		b.jitter = 1
+	}
+	b.maxDelay = utils.ToDuration(cfg.MaxDelay, 0)
+	if b.maxDelay < b.initialDelay {
+		b.maxDelay = b.initialDelay
+		if b.multiplier > 1 {
+			b.maxDelay = time.Hour
+		}
+	}
+	return b
+}
+
+// Next returns the delay for the next attempt, returns false if the max attempts reached
+func (b *backoff) Next() (time.Duration, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.maxAttempts > 0 && b.attempts >= b.maxAttempts {
+		b.nextRetry = time.Time{}
+		return 0, false
+	}
+
+	delay := float64(b.initialDelay)
+	for index := 0; index < b.attempts && delay < float64(b.maxDelay); index++ {
+		delay *= b.multiplier
+	}
+	if delay > float64(b.maxDelay) {
+		delay = float64(b.maxDelay)
+	}
+	if b.jitter > 0 {
+		delay += delay * b.jitter * (rand.Float64()*2 - 1) // #nosec G404 jitter does not need crypto random
+	}
+
+	nextDelay := time.Duration(delay).Round(time.Second)
+	if nextDelay < minReconnectDelay {
+		nextDelay = minReconnectDelay
+	}
+
+	b.attempts++
+	b.nextRetry = time.Now().Add(nextDelay)
+	return nextDelay, true
+}
+
+// Reset clears the attempts, should be called on successful connection
+func (b *backoff) Reset() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.attempts = 0
+	b.nextRetry = time.Time{}
+}
+
+// Status returns the number of attempts and the next retry time
+func (b *backoff) Status() ReconnectStatus {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	status := ReconnectStatus{Attempts: b.attempts}
+	if !b.nextRetry.IsZero() {
+		nextRetry := b.nextRetry
+		status.NextRetry = &nextRetry
+	}
+	return status
+}
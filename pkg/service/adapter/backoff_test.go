@@ -0,0 +1,67 @@
+package adapter
+
+import (
+	"testing"
+	"time"
+
+	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		testName     string
+		config       config.ReconnectPolicy
+		defaultDelay time.Duration
+		expected     []time.Duration
+		exhausted    bool
+	}{
+		{
+			testName:     "TestFixedDelay",
+			config:       config.ReconnectPolicy{},
+			defaultDelay: time.Second * 30,
+			expected:     []time.Duration{time.Second * 30, time.Second * 30, time.Second * 30},
+		},
+		{
+			testName:     "TestExponential",
+			config:       config.ReconnectPolicy{InitialDelay: "2s", Multiplier: 2, MaxDelay: "10s"},
+			defaultDelay: time.Second * 30,
+			expected:     []time.Duration{time.Second * 2, time.Second * 4, time.Second * 8, time.Second * 10, time.Second * 10},
+		},
+		{
+			testName:     "TestMaxAttempts",
+			config:       config.ReconnectPolicy{InitialDelay: "1s", Multiplier: 3, MaxDelay: "1m", MaxAttempts: 3},
+			defaultDelay: time.Second * 30,
+			expected:     []time.Duration{time.Second * 1, time.Second * 3, time.Second * 9},
+			exhausted:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			b := newBackoff(test.config, test.defaultDelay)
+			for _, expected := range test.expected {
+				delay, ok := b.Next()
+				assert.True(t, ok)
+				assert.Equal(t, expected, delay)
+				assert.NotNil(t, b.Status().NextRetry)
+			}
+			_, ok := b.Next()
+			assert.Equal(t, !test.exhausted, ok)
+
+			b.Reset()
+			assert.Equal(t, 0, b.Status().Attempts)
+			assert.Nil(t, b.Status().NextRetry)
+		})
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	b := newBackoff(config.ReconnectPolicy{InitialDelay: "10s", Jitter: 0.5}, time.Second)
+	for index := 0; index < 20; index++ {
+		delay, ok := b.Next()
+		assert.True(t, ok)
+		assert.GreaterOrEqual(t, delay, time.Second*5)
+		assert.LessOrEqual(t, delay, time.Second*15)
+	}
+}
@@ -0,0 +1,115 @@
+package adapter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mycontroller-org/2mqtt/pkg/types"
+	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
+	"github.com/mycontroller-org/server/v2/pkg/utils"
+)
+
+// default buffer settings
+const (
+	DefaultBufferMaxCount = 1000
+)
+
+// flushRequest is posted as a signal to a message queue to flush the buffered messages.
+// as the queue has a single consumer, flush and write are executed in order
+type flushRequest struct{}
+
+type bufferItem struct {
+	message *types.Message
+	addedAt time.Time
+}
+
+// messageBuffer holds the messages, those are not delivered to the target device
+type messageBuffer struct {
+	mutex      sync.Mutex
+	items      []bufferItem
+	maxCount   int
+	maxAge     time.Duration
+	dropOldest bool
+}
+
+func newMessageBuffer(cfg config.BufferConfig) *messageBuffer {
+	maxCount := cfg.MaxCount
+	if maxCount <= 0 {
+		maxCount = DefaultBufferMaxCount
+	}
+	return &messageBuffer{
+		items:      make([]bufferItem, 0),
+		maxCount:   maxCount,
+		maxAge:     utils.ToDuration(cfg.MaxAge, 0),
+		dropOldest: cfg.DropPolicy != config.BufferDropNewest,
+	}
+}
+
+// Add appends a message at the end of the buffer, returns the number of dropped messages
+func (b *messageBuffer) Add(message *types.Message) int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	dropped := b.removeExpired()
+	if len(b.items) >= b.maxCount {
+		if !b.dropOldest {
+			return dropped + 1
+		}
+		b.items = b.items[1:]
+		dropped++
+	}
+	b.items = append(b.items, bufferItem{message: message, addedAt: time.Now()})
+	return dropped
+}
+
+// Peek returns the oldest message and the number of expired messages dropped on the way
+func (b *messageBuffer) Peek() (*types.Message, int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	dropped := b.removeExpired()
+	if len(b.items) == 0 {
+		return nil, dropped
+	}
+	return b.items[0].message, dropped
+}
+
+// Remove removes the message from the head of the buffer, if it is still there
+func (b *messageBuffer) Remove(message *types.Message) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if len(b.items) > 0 && b.items[0].message == message {
+		b.items = b.items[1:]
+	}
+}
+
+// Clear removes all the messages, returns the number of removed messages
+func (b *messageBuffer) Clear() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	removed := len(b.items)
+	b.items = make([]bufferItem, 0)
+	return removed
+}
+
+// Len returns the number of messages in the buffer
+func (b *messageBuffer) Len() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.items)
+}
+
+// removes the messages older than max age, the caller should hold the lock
+func (b *messageBuffer) removeExpired() int {
+	if b.maxAge <= 0 {
+		return 0
+	}
+	index := 0
+	for index < len(b.items) && time.Since(b.items[index].addedAt) > b.maxAge {
+		index++
+	}
+	b.items = b.items[index:]
+	return index
+}
@@ -0,0 +1,119 @@
+package adapter
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mycontroller-org/2mqtt/pkg/queue"
+	"github.com/mycontroller-org/2mqtt/pkg/types"
+	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestMessageBuffer(t *testing.T) {
+	tests := []struct {
+		testName  string
+		config    config.BufferConfig
+		input     []string
+		sleep     time.Duration
+		expected  []string
+		dropCount int
+	}{
+		{
+			testName: "TestKeepOrder",
+			config:   config.BufferConfig{MaxCount: 5},
+			input:    []string{"m1", "m2", "m3"},
+			expected: []string{"m1", "m2", "m3"},
+		},
+		{
+			testName:  "TestDropOldest",
+			config:    config.BufferConfig{MaxCount: 2, DropPolicy: config.BufferDropOldest},
+			input:     []string{"m1", "m2", "m3"},
+			expected:  []string{"m2", "m3"},
+			dropCount: 1,
+		},
+		{
+			testName:  "TestDropNewest",
+			config:    config.BufferConfig{MaxCount: 2, DropPolicy: config.BufferDropNewest},
+			input:     []string{"m1", "m2", "m3"},
+			expected:  []string{"m1", "m2"},
+			dropCount: 1,
+		},
+		{
+			testName:  "TestMaxAge",
+			config:    config.BufferConfig{MaxCount: 5, MaxAge: "10ms"},
+			input:     []string{"m1", "m2"},
+			sleep:     time.Millisecond * 20,
+			expected:  []string{},
+			dropCount: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			buffer := newMessageBuffer(test.config)
+			dropCount := 0
+			for _, data := range test.input {
+				dropCount += buffer.Add(types.NewMessage([]byte(data)))
+			}
+			time.Sleep(test.sleep)
+
+			received := []string{}
+			for {
+				message, dropped := buffer.Peek()
+				dropCount += dropped
+				if message == nil {
+					break
+				}
+				buffer.Remove(message)
+				received = append(received, string(message.Data))
+			}
+			assert.Equal(t, test.expected, received)
+			assert.Equal(t, test.dropCount, dropCount)
+			assert.Equal(t, 0, buffer.Len())
+		})
+	}
+}
+
+func TestDrainBuffers(t *testing.T) {
+	tests := []struct {
+		testName string
+		mqttUP   bool
+		expected []string
+	}{
+		{testName: "TestFlushed", mqttUP: true, expected: []string{"m1", "m2"}},
+		{testName: "TestDeviceDown", mqttUP: false, expected: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			mqttQueue, err := queue.New("mqtt", 10, queue.OverflowDropNewest)
+			assert.NoError(t, err)
+			device := &recordDevice{}
+			s := &Service{
+				logger:           zap.NewNop(),
+				adapterConfig:    &config.AdapterConfig{Name: "test"},
+				mqttDevice:       device,
+				mqttMessageQueue: mqttQueue,
+				mqttBuffer:       newMessageBuffer(config.BufferConfig{}),
+				mutex:            &sync.RWMutex{},
+				sourceState:      newStateMachine(),
+				mqttState:        newStateMachine(),
+			}
+			s.mqttBuffer.Add(types.NewMessage([]byte("m1")))
+			s.mqttBuffer.Add(types.NewMessage([]byte("m2")))
+			if test.mqttUP {
+				assert.NoError(t, s.mqttState.Transition(types.StatusConnecting, ""))
+				assert.NoError(t, s.mqttState.Transition(types.StatusUP, ""))
+			}
+			mqttQueue.StartConsumers(1, s.mqttMessageProcessor)
+			defer mqttQueue.Stop()
+
+			s.drainQueues(time.Second)
+			assert.Equal(t, test.expected, device.written)
+			assert.Equal(t, 0, s.mqttBuffer.Len())
+		})
+	}
+}
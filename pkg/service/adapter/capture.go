@@ -0,0 +1,68 @@
+package adapter
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/mycontroller-org/2mqtt/pkg/capture"
+	"github.com/mycontroller-org/2mqtt/pkg/traffic"
+	"github.com/mycontroller-org/2mqtt/pkg/types"
+	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
+	contextTY "github.com/mycontroller-org/2mqtt/pkg/types/context"
+	"go.uber.org/zap"
+)
+
+const captureDirName = "capture"
+
+// opens the capture writer of the adapter
+func openCapture(ctx context.Context, logger *zap.Logger, adapterCfg *config.AdapterConfig) (*capture.Writer, error) {
+	dir := adapterCfg.Capture.Dir
+	if dir == "" {
+		dataDir := DefaultDataDir
+		if cfg, err := contextTY.ConfigFromContext(ctx); err == nil && cfg.DataDir != "" {
+			dataDir = cfg.DataDir
+		}
+		dir = filepath.Join(dataDir, captureDirName, adapterCfg.Name)
+	}
+
+	captureCfg := capture.Config{
+		Dir:      dir,
+		MaxSize:  adapterCfg.Capture.MaxSize,
+		MaxFiles: adapterCfg.Capture.MaxFiles,
+	}
+	logger.Debug("opening capture", zap.String("adapterName", adapterCfg.Name), zap.Any("config", captureCfg))
+	return capture.Open(captureCfg)
+}
+
+// captureMessage records the message, if the capture enabled and sends to the traffic monitors
+func (s *Service) captureMessage(direction, stage string, message *types.Message) {
+	if message == nil || (s.capture == nil && !traffic.HasSubscribers()) {
+		return
+	}
+	record := capture.Record{
+		Timestamp: time.Now(),
+		Adapter:   s.adapterConfig.Name,
+		Direction: direction,
+		Stage:     stage,
+		Data:      message.Data,
+		Others:    message.Others,
+	}
+	traffic.Publish(record)
+	if s.capture == nil {
+		return
+	}
+	if err := s.capture.Write(record); err != nil {
+		s.logger.Error("error on writing a capture record", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Error(err))
+	}
+}
+
+// closeCapture closes the capture file
+func (s *Service) closeCapture() {
+	if s.capture == nil {
+		return
+	}
+	if err := s.capture.Close(); err != nil {
+		s.logger.Error("error on closing capture", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Error(err))
+	}
+}
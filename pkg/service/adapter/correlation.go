@@ -0,0 +1,269 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mycontroller-org/2mqtt/pkg/types"
+	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
+	"github.com/mycontroller-org/server/v2/pkg/json"
+	"github.com/mycontroller-org/server/v2/pkg/utils"
+	js "github.com/mycontroller-org/server/v2/pkg/utils/javascript"
+	"go.uber.org/zap"
+)
+
+// request response defaults
+const (
+	DefaultCorrelationField   = "correlation_id"
+	DefaultResponseTopicField = "response_topic"
+	DefaultPayloadField       = "payload"
+	DefaultResponseTimeout    = time.Second * 5
+
+	responseStatusOK      = "ok"
+	responseStatusTimeout = "timeout"
+	responseStatusError   = "error"
+
+	// variables supplied to the match script, script should set "result" to true on match
+	scriptKeyRawData       = "raw_data"
+	scriptKeyRequest       = "request"
+	scriptKeyCorrelationID = "correlation_id"
+	matchScriptTimeout     = time.Second * 2
+)
+
+// response published on the response topic
+type correlationResponse struct {
+	CorrelationID string `json:"correlation_id"`
+	Status        string `json:"status"`
+	Payload       string `json:"payload,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+type pendingRequest struct {
+	correlationID string
+	responseTopic string
+	request       string
+	timer         *time.Timer
+}
+
+// correlator keeps the requests written to the source, in order, until a reply matched or timed out
+type correlator struct {
+	logger      *zap.Logger
+	cfg         config.RequestResponse
+	matchRegex  *regexp.Regexp
+	timeout     time.Duration
+	mutex       sync.Mutex
+	pending     []*pendingRequest
+	publishFunc func(responseTopic string, response correlationResponse)
+}
+
+func newCorrelator(logger *zap.Logger, cfg config.RequestResponse, publishFunc func(string, correlationResponse)) (*correlator, error) {
+	if cfg.CorrelationField == "" {
+		cfg.CorrelationField = DefaultCorrelationField
+	}
+	if cfg.ResponseTopicField == "" {
+		cfg.ResponseTopicField = DefaultResponseTopicField
+	}
+	if cfg.PayloadField == "" {
+		cfg.PayloadField = DefaultPayloadField
+	}
+
+	c := &correlator{
+		logger:      logger,
+		cfg:         cfg,
+		timeout:     utils.ToDuration(cfg.Timeout, DefaultResponseTimeout),
+		pending:     make([]*pendingRequest, 0),
+		publishFunc: publishFunc,
+	}
+	// without a rule any source message would be taken as the reply
+	if cfg.MatchRegex == "" && cfg.MatchScript == "" {
+		return nil, errors.New("request_response requires match_regex or match_script")
+	}
+	if cfg.MatchRegex != "" {
+		matchRegex, err := regexp.Compile(cfg.MatchRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid request_response match_regex: %w", err)
+		}
+		c.matchRegex = matchRegex
+	}
+	return c, nil
+}
+
+// ToRequest returns the source bound message, if the mqtt message is a request.
+// returns nil, if the message is not a json or does not have the correlation field.
+// a request without the payload field is rejected with an error response
+func (c *correlator) ToRequest(message *types.Message) (*types.Message, error) {
+	data := make(map[string]interface{})
+	if err := json.Unmarshal(message.Data, &data); err != nil {
+		return nil, nil
+	}
+	correlationID := toString(data[c.cfg.CorrelationField])
+	if correlationID == "" {
+		return nil, nil
+	}
+	responseTopic := toString(data[c.cfg.ResponseTopicField])
+	if responseTopic == "" {
+		responseTopic = c.cfg.ResponseTopic
+	}
+
+	payload, found := data[c.cfg.PayloadField]
+	if !found || payload == nil {
+		err := fmt.Errorf("payload field [%s] not found on the request", c.cfg.PayloadField)
+		c.publishFunc(responseTopic, correlationResponse{
+			CorrelationID: correlationID,
+			Status:        responseStatusError,
+			Error:         err.Error(),
+		})
+		return nil, err
+	}
+
+	request := types.NewMessage([]byte(toString(payload)))
+	request.Timestamp = message.Timestamp
+	for key, value := range message.Others {
+		request.Others[key] = value
+	}
+	request.Others.Set(types.KeyCorrelationID, correlationID, nil)
+	request.Others.Set(types.KeyResponseTopic, responseTopic, nil)
+	return request, nil
+}
+
+// Register starts waiting for the reply, should be called before writing the request to the source.
+// returns nil, if the message is not a request
+func (c *correlator) Register(message *types.Message) *pendingRequest {
+	correlationID := message.Others.GetString(types.KeyCorrelationID)
+	if correlationID == "" {
+		return nil
+	}
+	request := &pendingRequest{
+		correlationID: correlationID,
+		responseTopic: message.Others.GetString(types.KeyResponseTopic),
+		request:       string(message.Data),
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	request.timer = time.AfterFunc(c.timeout, func() { c.onTimeout(request) })
+	c.pending = append(c.pending, request)
+	return request
+}
+
+// Unregister drops the request without a response, used when the request not written to the source
+func (c *correlator) Unregister(request *pendingRequest) {
+	if request == nil {
+		return
+	}
+	if c.remove(request) {
+		request.timer.Stop()
+	}
+}
+
+// remove returns false, if the request is not pending
+func (c *correlator) remove(request *pendingRequest) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for index, pending := range c.pending {
+		if pending == request {
+			c.pending = append(c.pending[:index], c.pending[index+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Match publishes the source message as a response of the oldest matching request.
+// returns true, if the message matched with a request.
+// the rules are evaluated without holding the lock, the match script may take a while
+func (c *correlator) Match(message *types.Message) bool {
+	c.mutex.Lock()
+	pending := append([]*pendingRequest{}, c.pending...)
+	c.mutex.Unlock()
+
+	for _, request := range pending {
+		if !c.isMatch(request, message) {
+			continue
+		}
+		// timed out or unregistered meanwhile
+		if !c.remove(request) {
+			continue
+		}
+		request.timer.Stop()
+		c.publishFunc(request.responseTopic, correlationResponse{
+			CorrelationID: request.correlationID,
+			Status:        responseStatusOK,
+			Payload:       string(message.Data),
+		})
+		return true
+	}
+	return false
+}
+
+func (c *correlator) isMatch(request *pendingRequest, message *types.Message) bool {
+	if c.matchRegex != nil && !c.matchRegex.Match(message.Data) {
+		return false
+	}
+	if c.cfg.MatchScript != "" {
+		variables := map[string]interface{}{
+			scriptKeyRawData:       string(message.Data),
+			scriptKeyRequest:       request.request,
+			scriptKeyCorrelationID: request.correlationID,
+		}
+		timeout := matchScriptTimeout
+		result, err := js.Execute(c.logger, c.cfg.MatchScript, variables, &timeout)
+		if err != nil {
+			c.logger.Error("error on executing request_response match script", zap.String("correlationID", request.correlationID), zap.Error(err))
+			return false
+		}
+		return strings.EqualFold(toString(result), "true")
+	}
+	return true
+}
+
+func (c *correlator) onTimeout(request *pendingRequest) {
+	if !c.remove(request) {
+		return
+	}
+	c.publishFunc(request.responseTopic, correlationResponse{
+		CorrelationID: request.correlationID,
+		Status:        responseStatusTimeout,
+		Error:         fmt.Sprintf("no response from the source within %s", c.timeout),
+	})
+}
+
+// Close drops the pending requests
+func (c *correlator) Close() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, request := range c.pending {
+		request.timer.Stop()
+	}
+	c.pending = make([]*pendingRequest, 0)
+}
+
+func toString(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	if stringValue, ok := value.(string); ok {
+		return stringValue
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// publishResponse posts the response to the mqtt queue
+func (s *Service) publishResponse(responseTopic string, response correlationResponse) {
+	if responseTopic == "" {
+		s.logger.Warn("response topic not available, response dropped", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("correlationID", response.CorrelationID))
+		return
+	}
+	data, err := json.Marshal(response)
+	if err != nil {
+		s.logger.Error("error on converting response to json", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Error(err))
+		return
+	}
+	message := types.NewMessage(data)
+	message.Others.Set(types.KeyMqttAbsoluteTopic, responseTopic, nil)
+	s.produce(s.mqttMessageQueue, message, deviceMqtt)
+}
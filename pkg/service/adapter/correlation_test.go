@@ -0,0 +1,112 @@
+package adapter
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mycontroller-org/2mqtt/pkg/types"
+	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type responseRecorder struct {
+	mutex     sync.Mutex
+	topics    []string
+	responses []correlationResponse
+}
+
+func (rr *responseRecorder) publish(topic string, response correlationResponse) {
+	rr.mutex.Lock()
+	defer rr.mutex.Unlock()
+	rr.topics = append(rr.topics, topic)
+	rr.responses = append(rr.responses, response)
+}
+
+func TestCorrelatorToRequest(t *testing.T) {
+	recorder := &responseRecorder{}
+	c, err := newCorrelator(zap.NewNop(), config.RequestResponse{Enabled: true, ResponseTopic: "replies", MatchRegex: "."}, recorder.publish)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		testName              string
+		data                  string
+		expectedRequest       bool
+		expectedError         bool
+		expectedData          string
+		expectedResponseTopic string
+	}{
+		{testName: "TestNotJSON", data: "1;255;3;0;2;", expectedRequest: false},
+		{testName: "TestNoCorrelationID", data: `{"payload":"1;255;3;0;2;"}`, expectedRequest: false},
+		{testName: "TestDefaultTopic", data: `{"correlation_id":"abc","payload":"1;255;3;0;2;"}`, expectedRequest: true, expectedData: "1;255;3;0;2;", expectedResponseTopic: "replies"},
+		{testName: "TestResponseTopic", data: `{"correlation_id":12,"response_topic":"replies/12","payload":"ping"}`, expectedRequest: true, expectedData: "ping", expectedResponseTopic: "replies/12"},
+		{testName: "TestNoPayload", data: `{"correlation_id":"abc","response_topic":"replies/abc"}`, expectedRequest: false, expectedError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			request, err := c.ToRequest(types.NewMessage([]byte(test.data)))
+			assert.Equal(t, test.expectedError, err != nil)
+			if !test.expectedRequest {
+				assert.Nil(t, request)
+				return
+			}
+			assert.NotNil(t, request)
+			assert.Equal(t, test.expectedData, string(request.Data))
+			assert.Equal(t, test.expectedResponseTopic, request.Others.GetString(types.KeyResponseTopic))
+			assert.NotEmpty(t, request.Others.GetString(types.KeyCorrelationID))
+		})
+	}
+
+	// rejected request is answered with an error
+	assert.Equal(t, []string{"replies/abc"}, recorder.topics)
+	assert.Equal(t, responseStatusError, recorder.responses[0].Status)
+	assert.Equal(t, "abc", recorder.responses[0].CorrelationID)
+}
+
+func TestCorrelatorMatchAndTimeout(t *testing.T) {
+	recorder := &responseRecorder{}
+	c, err := newCorrelator(zap.NewNop(), config.RequestResponse{Enabled: true, MatchRegex: "^pong", Timeout: "100ms"}, recorder.publish)
+	assert.NoError(t, err)
+
+	first, _ := c.ToRequest(types.NewMessage([]byte(`{"correlation_id":"1","response_topic":"replies/1","payload":"ping"}`)))
+	second, _ := c.ToRequest(types.NewMessage([]byte(`{"correlation_id":"2","response_topic":"replies/2","payload":"ping"}`)))
+	c.Register(first)
+	c.Register(second)
+
+	assert.False(t, c.Match(types.NewMessage([]byte("other"))))
+	assert.True(t, c.Match(types.NewMessage([]byte("pong 1"))))
+
+	time.Sleep(200 * time.Millisecond)
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	assert.Equal(t, []string{"replies/1", "replies/2"}, recorder.topics)
+	assert.Equal(t, correlationResponse{CorrelationID: "1", Status: responseStatusOK, Payload: "pong 1"}, recorder.responses[0])
+	assert.Equal(t, "2", recorder.responses[1].CorrelationID)
+	assert.Equal(t, responseStatusTimeout, recorder.responses[1].Status)
+}
+
+func TestCorrelatorRegister(t *testing.T) {
+	_, err := newCorrelator(zap.NewNop(), config.RequestResponse{Enabled: true}, nil)
+	assert.Error(t, err)
+
+	recorder := &responseRecorder{}
+	c, err := newCorrelator(zap.NewNop(), config.RequestResponse{Enabled: true, MatchRegex: "^pong", Timeout: "50ms"}, recorder.publish)
+	assert.NoError(t, err)
+
+	assert.Nil(t, c.Register(types.NewMessage([]byte("not a request"))))
+
+	// write failed, no timeout response expected
+	request, _ := c.ToRequest(types.NewMessage([]byte(`{"correlation_id":"1","response_topic":"replies/1","payload":"ping"}`)))
+	failed := c.Register(request)
+	assert.NotNil(t, failed)
+	c.Unregister(failed)
+	assert.False(t, c.Match(types.NewMessage([]byte("pong"))))
+
+	time.Sleep(100 * time.Millisecond)
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	assert.Empty(t, recorder.responses)
+}
@@ -0,0 +1,41 @@
+package adapter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mycontroller-org/2mqtt/pkg/service/metrics"
+	"github.com/mycontroller-org/2mqtt/pkg/types"
+	"go.uber.org/zap"
+)
+
+// dryRun logs the formatted message and publishes it to the shadow topic, the message is not written to the device
+func (s *Service) dryRun(direction string, message *types.Message) {
+	metrics.Inc(metrics.MessagesDryRun, s.adapterConfig.Name, direction)
+	s.logger.Info("dry run, message not written", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("direction", direction), zap.String("message", message.ToString()))
+
+	shadowTopic := s.adapterConfig.DryRun.ShadowTopic
+	if shadowTopic == "" {
+		return
+	}
+	topic := fmt.Sprintf("%s/%s", strings.TrimSuffix(shadowTopic, "/"), direction)
+	if direction == directionToMqtt {
+		mqttTopic := message.Others.GetString(types.KeyMqttAbsoluteTopic)
+		if mqttTopic == "" {
+			mqttTopic = message.Others.GetString(types.KeyMqttTopic)
+		}
+		if mqttTopic = strings.TrimPrefix(mqttTopic, "/"); mqttTopic != "" {
+			topic = fmt.Sprintf("%s/%s", topic, mqttTopic)
+		}
+	}
+
+	if !s.isMqttUP() {
+		s.logger.Debug("mqtt is not available, shadow message not published", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("topic", topic))
+		return
+	}
+	shadowMessage := types.NewMessage(message.Data)
+	shadowMessage.Others.Set(types.KeyMqttAbsoluteTopic, topic, nil)
+	if err := s.getMqttDevice().Write(shadowMessage); err != nil {
+		s.logger.Error("error on publishing a message to shadow topic", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("topic", topic), zap.Error(err))
+	}
+}
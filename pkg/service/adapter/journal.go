@@ -0,0 +1,199 @@
+package adapter
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/mycontroller-org/2mqtt/pkg/journal"
+	"github.com/mycontroller-org/2mqtt/pkg/types"
+	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
+	contextTY "github.com/mycontroller-org/2mqtt/pkg/types/context"
+	"github.com/mycontroller-org/server/v2/pkg/json"
+	"github.com/mycontroller-org/server/v2/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// default journal settings
+const (
+	DefaultDataDir = "./data"
+	journalDirName = "journal"
+
+	journalReplayBatchSize = 100         // records read from the journal at once
+	journalRetryDelay      = time.Second // delivery retried after a failed publish, even there is no new message
+)
+
+// opens the journal of the adapter
+func openJournal(ctx context.Context, logger *zap.Logger, adapterCfg *config.AdapterConfig) (*journal.Journal, error) {
+	dir := adapterCfg.Journal.Dir
+	if dir == "" {
+		dataDir := DefaultDataDir
+		if cfg, err := contextTY.ConfigFromContext(ctx); err == nil && cfg.DataDir != "" {
+			dataDir = cfg.DataDir
+		}
+		dir = filepath.Join(dataDir, journalDirName, adapterCfg.Name)
+	}
+
+	journalCfg := journal.Config{
+		Dir:           dir,
+		FsyncPolicy:   adapterCfg.Journal.FsyncPolicy,
+		FsyncInterval: utils.ToDuration(adapterCfg.Journal.FsyncInterval, journal.DefaultFsyncInterval),
+		SegmentSize:   adapterCfg.Journal.SegmentSize,
+	}
+	logger.Debug("opening journal", zap.String("adapterName", adapterCfg.Name), zap.Any("config", journalCfg))
+	return journal.Open(logger, journalCfg)
+}
+
+// appendJournal persists the message and keeps the sequence number on the message
+func (s *Service) appendJournal(message *types.Message) {
+	if s.journal == nil || message == nil {
+		return
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		s.logger.Error("error on converting a message to json", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Error(err))
+		return
+	}
+	sequence, err := s.journal.Append(data)
+	if err != nil {
+		s.logger.Error("error on writing a message to journal", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Error(err))
+		return
+	}
+	if message.Others == nil {
+		message.Others = make(map[string]interface{})
+	}
+	message.Others.Set(types.KeyJournalSequence, sequence, nil)
+}
+
+// journalReplayRequest is posted as a signal to the mqtt queue to deliver the pending journal records
+type journalReplayRequest struct{}
+
+// journalSequence returns the journal sequence of the message, false if the message is not journaled
+func journalSequence(message *types.Message) (uint64, bool) {
+	if message == nil || message.Others == nil {
+		return 0, false
+	}
+	sequence, ok := message.Others.Get(types.KeyJournalSequence).(uint64)
+	return sequence, ok
+}
+
+// ackJournal acknowledges the message and all the previous messages.
+// journaled messages are delivered strictly in order, hence the acknowledgement is cumulative
+func (s *Service) ackJournal(sequence uint64) {
+	if err := s.journal.Ack(sequence); err != nil {
+		s.logger.Error("error on acknowledging a journal entry", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Uint64("sequence", sequence), zap.Error(err))
+	}
+}
+
+// requestJournalReplay delivers the pending journal records on the mqtt queue consumer
+func (s *Service) requestJournalReplay() {
+	if s.journal == nil {
+		return
+	}
+	s.journalReplay.Store(true)
+	s.mqttMessageQueue.Signal(journalReplayRequest{})
+}
+
+// scheduleJournalRetry requests the journal replay after the retry delay, only one retry is pending at a time.
+// if mqtt is not up at that time, the replay is requested again on connect
+func (s *Service) scheduleJournalRetry() {
+	if !s.journalRetry.CompareAndSwap(false, true) {
+		return
+	}
+	time.AfterFunc(journalRetryDelay, func() {
+		s.journalRetry.Store(false)
+		s.requestJournalReplay()
+	})
+}
+
+// journalDeliverable returns true, if the journaled messages can be delivered
+func (s *Service) journalDeliverable() bool {
+	return s.adapterConfig.DryRun.Enabled || s.isMqttUP()
+}
+
+// deliverJournaled delivers the message only if it is the next record of the journal.
+// journaled messages are not buffered, those are kept on the journal until delivered.
+// a gap (the previous records dropped by the queue or not delivered) is delivered from the journal
+func (s *Service) deliverJournaled(message *types.Message, sequence uint64) {
+	acked := s.journal.Acked()
+	switch {
+	case sequence <= acked: // delivered from the journal
+		return
+
+	case sequence == acked+1:
+		if !s.journalDeliverable() {
+			return
+		}
+		_ = s.publishJournaled(message, sequence)
+
+	default:
+		s.replayJournal()
+	}
+}
+
+// publishJournaled writes the message to mqtt and acknowledges it on the journal.
+// on failure the pending records are delivered again after the retry delay
+func (s *Service) publishJournaled(message *types.Message, sequence uint64) error {
+	if s.adapterConfig.DryRun.Enabled {
+		s.dryRun(directionToMqtt, message)
+	} else if err := s.writeToMqtt(message); err != nil {
+		s.scheduleJournalRetry()
+		return err
+	}
+	s.ackJournal(sequence)
+	return nil
+}
+
+// replayJournal delivers the pending records in batches, until mqtt is not available or the journal is completed
+func (s *Service) replayJournal() {
+	if s.journal == nil {
+		return
+	}
+	replayed := 0
+	defer func() {
+		if replayed > 0 {
+			s.logger.Info("replayed pending messages from journal", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Int("count", replayed))
+		}
+	}()
+
+	for s.journalDeliverable() {
+		records, err := s.journal.Pending(journalReplayBatchSize)
+		if err != nil {
+			s.logger.Error("error on reading pending messages from journal", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Error(err))
+			return
+		}
+		if len(records) == 0 {
+			return
+		}
+		for _, record := range records {
+			if !s.journalDeliverable() {
+				return
+			}
+			message := &types.Message{}
+			if err := json.Unmarshal(record.Data, message); err != nil {
+				// can not be delivered, skipped not to block the journal
+				s.logger.Error("error on parsing a journal entry, skipped", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Uint64("sequence", record.Sequence), zap.Error(err))
+				s.ackJournal(record.Sequence)
+				continue
+			}
+			if message.Others == nil {
+				message.Others = make(map[string]interface{})
+			}
+			message.Others.Set(types.KeyJournalSequence, record.Sequence, nil)
+			if err := s.publishJournaled(message, record.Sequence); err != nil {
+				return
+			}
+			replayed++
+		}
+	}
+}
+
+// closeJournal syncs and closes the journal
+func (s *Service) closeJournal() {
+	if s.journal == nil {
+		return
+	}
+	if err := s.journal.Close(); err != nil {
+		s.logger.Error("error on closing journal", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Error(err))
+	}
+}
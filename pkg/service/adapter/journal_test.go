@@ -0,0 +1,130 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mycontroller-org/2mqtt/pkg/journal"
+	"github.com/mycontroller-org/2mqtt/pkg/queue"
+	"github.com/mycontroller-org/2mqtt/pkg/types"
+	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type recordDevice struct {
+	mutex    sync.Mutex
+	failures int // number of writes to be failed
+	written  []string
+}
+
+func (d *recordDevice) Close() error { return nil }
+
+func (d *recordDevice) Write(message *types.Message) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.failures > 0 {
+		d.failures--
+		return errors.New("write failed")
+	}
+	d.written = append(d.written, string(message.Data))
+	return nil
+}
+
+func (d *recordDevice) Written() []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return append([]string{}, d.written...)
+}
+
+func TestJournalReplay(t *testing.T) {
+	logger := zap.NewNop()
+	_journal, err := journal.Open(logger, journal.Config{Dir: t.TempDir()})
+	assert.NoError(t, err)
+	defer _journal.Close()
+	mqttQueue, err := queue.New("mqtt", 10, queue.OverflowDropOldest)
+	assert.NoError(t, err)
+
+	device := &recordDevice{}
+	adapterCfg := &config.AdapterConfig{Name: "test", Buffer: config.BufferConfig{MaxCount: 2, DropPolicy: config.BufferDropOldest}}
+	s := &Service{
+		logger:           logger,
+		adapterConfig:    adapterCfg,
+		mqttDevice:       device,
+		mqttMessageQueue: mqttQueue,
+		mqttBuffer:       newMessageBuffer(adapterCfg.Buffer),
+		journal:          _journal,
+		mutex:            &sync.RWMutex{},
+		sourceState:      newStateMachine(),
+		mqttState:        newStateMachine(),
+	}
+
+	// mqtt is down, more messages than the buffer max count and the replay batch size
+	count := journalReplayBatchSize + 5
+	expected := []string{}
+	for index := 1; index <= count; index++ {
+		message := types.NewMessage([]byte(fmt.Sprintf("m%d", index)))
+		s.appendJournal(message)
+		s.mqttMessageProcessor(message)
+		expected = append(expected, string(message.Data))
+	}
+	assert.Empty(t, device.Written())
+	assert.Equal(t, 0, s.mqttBuffer.Len())
+	assert.Equal(t, uint64(0), _journal.Acked())
+
+	// mqtt is up, all the messages delivered in order
+	assert.NoError(t, s.mqttState.Transition(types.StatusConnecting, ""))
+	assert.NoError(t, s.mqttState.Transition(types.StatusUP, ""))
+	s.requestJournalReplay()
+	s.mqttMessageProcessor(journalReplayRequest{})
+	assert.Equal(t, expected, device.Written())
+	assert.Equal(t, uint64(count), _journal.Acked())
+
+	// a message dropped by the queue is delivered from the journal, before the next message
+	dropped := types.NewMessage([]byte("dropped"))
+	s.appendJournal(dropped)
+	next := types.NewMessage([]byte("next"))
+	s.appendJournal(next)
+	s.mqttMessageProcessor(next)
+	s.mqttMessageProcessor(dropped)
+	assert.Equal(t, append(expected, "dropped", "next"), device.Written())
+	assert.Equal(t, uint64(count+2), _journal.Acked())
+}
+
+func TestJournalRetry(t *testing.T) {
+	logger := zap.NewNop()
+	_journal, err := journal.Open(logger, journal.Config{Dir: t.TempDir()})
+	assert.NoError(t, err)
+	defer _journal.Close()
+	mqttQueue, err := queue.New("mqtt", 10, queue.OverflowDropNewest)
+	assert.NoError(t, err)
+
+	device := &recordDevice{failures: 1}
+	s := &Service{
+		logger:           logger,
+		adapterConfig:    &config.AdapterConfig{Name: "test"},
+		mqttDevice:       device,
+		mqttMessageQueue: mqttQueue,
+		journal:          _journal,
+		mutex:            &sync.RWMutex{},
+		sourceState:      newStateMachine(),
+		mqttState:        newStateMachine(),
+	}
+	assert.NoError(t, s.mqttState.Transition(types.StatusConnecting, ""))
+	assert.NoError(t, s.mqttState.Transition(types.StatusUP, ""))
+	mqttQueue.StartConsumers(1, s.mqttMessageProcessor)
+	defer mqttQueue.Stop()
+
+	// publish fails, delivered by the retry without a new message
+	message := types.NewMessage([]byte("m1"))
+	s.appendJournal(message)
+	mqttQueue.Produce("", message)
+	assert.Eventually(t, func() bool {
+		return len(device.Written()) == 1
+	}, journalRetryDelay*3, 10*time.Millisecond)
+	assert.Equal(t, []string{"m1"}, device.Written())
+	assert.Equal(t, uint64(1), _journal.Acked())
+}
@@ -0,0 +1,137 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+
+	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
+	contextTY "github.com/mycontroller-org/2mqtt/pkg/types/context"
+	"go.uber.org/zap"
+)
+
+// ErrAdapterNotFound returned when the adapter is not defined on the config
+var ErrAdapterNotFound = errors.New("adapter not found")
+
+// Info of an adapter, secrets are masked on the config
+type Info struct {
+	Config config.AdapterConfig `json:"config"`
+	Status Status               `json:"status"`
+}
+
+// List returns all the adapters, includes disabled adapters
+func List() []Info {
+	adapters := servicesStore.Adapters()
+	items := make([]Info, 0, len(adapters))
+	for _, adapterCfg := range adapters {
+		items = append(items, Info{Config: adapterCfg.Masked(), Status: GetStatus(adapterCfg.Name)})
+	}
+	return items
+}
+
+// Get returns an adapter
+func Get(name string) (*Info, error) {
+	adapterCfg, err := getAdapterConfig(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Info{Config: adapterCfg.Masked(), Status: GetStatus(name)}, nil
+}
+
+// StartAdapter starts an adapter, the adapter should be enabled
+func StartAdapter(name string) error {
+	servicesStore.actionMutex.Lock()
+	defer servicesStore.actionMutex.Unlock()
+
+	return startAdapter(name)
+}
+
+// StopAdapter stops a running adapter
+func StopAdapter(name string) error {
+	servicesStore.actionMutex.Lock()
+	defer servicesStore.actionMutex.Unlock()
+
+	return stopAdapter(name)
+}
+
+// RestartAdapter stops and starts an adapter
+func RestartAdapter(name string) error {
+	servicesStore.actionMutex.Lock()
+	defer servicesStore.actionMutex.Unlock()
+
+	if servicesStore.Get(name) != nil {
+		if err := stopAdapter(name); err != nil {
+			return err
+		}
+	}
+	return startAdapter(name)
+}
+
+// EnableAdapter enables and starts an adapter, not persisted on the config file
+func EnableAdapter(name string) error {
+	servicesStore.actionMutex.Lock()
+	defer servicesStore.actionMutex.Unlock()
+
+	if !servicesStore.UpdateEnabled(name, true) {
+		return fmt.Errorf("%w, name:%s", ErrAdapterNotFound, name)
+	}
+	if servicesStore.Get(name) != nil {
+		return nil
+	}
+	return startAdapter(name)
+}
+
+// DisableAdapter stops and disables an adapter, not persisted on the config file
+func DisableAdapter(name string) error {
+	servicesStore.actionMutex.Lock()
+	defer servicesStore.actionMutex.Unlock()
+
+	if !servicesStore.UpdateEnabled(name, false) {
+		return fmt.Errorf("%w, name:%s", ErrAdapterNotFound, name)
+	}
+	servicesStore.Remove(name)
+	return nil
+}
+
+func startAdapter(name string) error {
+	adapterCfg, err := getAdapterConfig(name)
+	if err != nil {
+		return err
+	}
+	if !adapterCfg.Enabled {
+		return fmt.Errorf("adapter is disabled, name:%s", name)
+	}
+	if servicesStore.Get(name) != nil {
+		return fmt.Errorf("adapter is running, name:%s", name)
+	}
+
+	ctx := servicesStore.Context()
+	if ctx == nil {
+		return fmt.Errorf("adapter services are not started")
+	}
+	logger, err := contextTY.LoggerFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	logger.Info("starting an adapter on request", zap.String("name", name))
+	return startService(ctx, logger, adapterCfg)
+}
+
+func stopAdapter(name string) error {
+	if _, err := getAdapterConfig(name); err != nil {
+		return err
+	}
+	if servicesStore.Get(name) == nil {
+		return fmt.Errorf("adapter is not running, name:%s", name)
+	}
+	servicesStore.Remove(name)
+	return nil
+}
+
+func getAdapterConfig(name string) (config.AdapterConfig, error) {
+	for _, adapterCfg := range servicesStore.Adapters() {
+		if adapterCfg.Name == name {
+			return adapterCfg, nil
+		}
+	}
+	return config.AdapterConfig{}, fmt.Errorf("%w, name:%s", ErrAdapterNotFound, name)
+}
@@ -0,0 +1,79 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mycontroller-org/2mqtt/pkg/pipeline"
+	"github.com/mycontroller-org/2mqtt/pkg/service/metrics"
+	"github.com/mycontroller-org/2mqtt/pkg/types"
+	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
+	"go.uber.org/zap"
+)
+
+// reported on filtered metrics, when a pipeline stage dropped a message
+const filterReasonPipeline = "pipeline"
+
+// errPipelineDropped returned when a pipeline stage dropped the message or failed
+var errPipelineDropped = errors.New("message dropped by the pipeline")
+
+// pipelines executed before and after the provider formatter
+type directionPipeline struct {
+	before *pipeline.Pipeline
+	after  *pipeline.Pipeline
+}
+
+func newDirectionPipeline(logger *zap.Logger, cfg config.DirectionPipeline) (directionPipeline, error) {
+	before, err := pipeline.New(logger, cfg.Before)
+	if err != nil {
+		return directionPipeline{}, err
+	}
+	after, err := pipeline.New(logger, cfg.After)
+	if err != nil {
+		return directionPipeline{}, err
+	}
+	return directionPipeline{before: before, after: after}, nil
+}
+
+// runPipeline returns false, if the message dropped by a stage or failed
+func (s *Service) runPipeline(p *pipeline.Pipeline, direction string, message *types.Message) (*types.Message, bool) {
+	if p == nil || message == nil {
+		return message, true
+	}
+	processed, err := p.Process(message)
+	if err != nil {
+		metrics.Inc(metrics.FormatterErrors, s.adapterConfig.Name, direction)
+		s.logger.Error("error on executing pipeline", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("direction", direction), zap.Error(err))
+		return nil, false
+	}
+	if processed == nil {
+		return nil, !s.filterMessage(direction, filterReasonPipeline, message)
+	}
+	return processed, true
+}
+
+// formatMessage executes the pipelines and the provider formatter of the direction.
+// serialized on each direction, the formatter and the pipeline scripts are not safe for concurrent use
+func (s *Service) formatMessage(direction string, message *types.Message) (*types.Message, error) {
+	p, formatFunc, mutex := s.toMqttPipeline, s.provider.ToMQTTMessage, &s.toMqttFormat
+	if direction == directionToSource {
+		p, formatFunc, mutex = s.toSourcePipeline, s.provider.ToSourceMessage, &s.toSourceFormat
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	message, ok := s.runPipeline(p.before, direction, message)
+	if !ok {
+		return nil, errPipelineDropped
+	}
+	formattedMsg, err := formatFunc(message)
+	if err != nil {
+		metrics.Inc(metrics.FormatterErrors, s.adapterConfig.Name, direction)
+		s.logger.Error("error on formatting a message", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("direction", direction), zap.Error(err))
+		return nil, fmt.Errorf("error on formatting: %w", err)
+	}
+	if formattedMsg, ok = s.runPipeline(p.after, direction, formattedMsg); !ok {
+		return nil, errPipelineDropped
+	}
+	return formattedMsg, nil
+}
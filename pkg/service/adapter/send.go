@@ -0,0 +1,114 @@
+package adapter
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/mycontroller-org/2mqtt/pkg/capture"
+	"github.com/mycontroller-org/2mqtt/pkg/types"
+	"go.uber.org/zap"
+)
+
+// directions of a send request
+const (
+	DirectionToSource = directionToSource
+	DirectionToMqtt   = directionToMqtt
+)
+
+// SendRequest of a message to be injected into a running adapter
+type SendRequest struct {
+	Direction string                 `json:"direction"` // to_source or to_mqtt
+	Data      string                 `json:"data"`
+	Hex       bool                   `json:"hex"`    // data is hex encoded, used for the binary data
+	Others    map[string]interface{} `json:"others"` // example: mqtt_topic
+	Format    bool                   `json:"format"` // executes the pipelines and the provider formatter of the direction
+}
+
+// SendResult of a send request, holds the message as written to the device
+type SendResult struct {
+	Direction string                 `json:"direction"`
+	DryRun    bool                   `json:"dry_run"` // message not written, adapter is on dry run
+	Data      string                 `json:"data"`
+	Others    map[string]interface{} `json:"others"`
+}
+
+// Send injects a message into a running adapter, written to the device synchronously bypassing the queue
+func Send(name string, request SendRequest) (*SendResult, error) {
+	service := servicesStore.Get(name)
+	if service == nil {
+		if _, err := getAdapterConfig(name); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("adapter is not running, name:%s", name)
+	}
+
+	data := []byte(request.Data)
+	if request.Hex {
+		decoded, err := hex.DecodeString(request.Data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex data: %w", err)
+		}
+		data = decoded
+	}
+	message := types.NewMessage(data)
+	for key, value := range request.Others {
+		message.Others.Set(key, value, nil)
+	}
+
+	written, err := service.send(request.Direction, message, request.Format)
+	if err != nil {
+		return nil, err
+	}
+	return &SendResult{
+		Direction: request.Direction,
+		DryRun:    service.adapterConfig.DryRun.Enabled,
+		Data:      string(written.Data),
+		Others:    written.Others,
+	}, nil
+}
+
+// send formats the message optionally and writes it to the device of the direction.
+// formatting and writing are serialized with the queue consumer of the direction
+func (s *Service) send(direction string, message *types.Message, format bool) (*types.Message, error) {
+	var isUP func() bool
+	var writeFunc func(*types.Message) error
+	var deviceName string
+	switch direction {
+	case directionToSource:
+		isUP, writeFunc, deviceName = s.isSourceUP, s.writeToSource, deviceSource
+	case directionToMqtt:
+		isUP, writeFunc, deviceName = s.isMqttUP, s.writeToMqtt, deviceMqtt
+	default:
+		return nil, fmt.Errorf("unsupported direction [%s], options: %s, %s", direction, directionToSource, directionToMqtt)
+	}
+
+	s.logger.Info("sending a message on request", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("direction", direction), zap.Bool("format", format), zap.String("message", message.ToString()))
+
+	if format {
+		formattedMsg, err := s.formatMessage(direction, message)
+		if err != nil {
+			return nil, err
+		}
+		if formattedMsg == nil {
+			return nil, errors.New("message ignored by the formatter")
+		}
+		message = formattedMsg
+	}
+	if message.Others == nil {
+		message.Others = make(map[string]interface{})
+	}
+
+	s.captureMessage(direction, capture.StageFormatted, message)
+	if s.adapterConfig.DryRun.Enabled {
+		s.dryRun(direction, message)
+		return message, nil
+	}
+	if !isUP() {
+		return nil, fmt.Errorf("%s device is not available", deviceName)
+	}
+	if err := writeFunc(message); err != nil {
+		return nil, fmt.Errorf("error on writing: %w", err)
+	}
+	return message, nil
+}
@@ -0,0 +1,61 @@
+package adapter
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mycontroller-org/2mqtt/pkg/types"
+	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// overlapDevice reports, if the writes overlapped
+type overlapDevice struct {
+	writing    atomic.Int32
+	overlapped atomic.Bool
+}
+
+func (d *overlapDevice) Close() error { return nil }
+
+func (d *overlapDevice) Write(message *types.Message) error {
+	if d.writing.Add(1) > 1 {
+		d.overlapped.Store(true)
+	}
+	time.Sleep(time.Millisecond)
+	d.writing.Add(-1)
+	return nil
+}
+
+func TestSendSerializedWithConsumer(t *testing.T) {
+	device := &overlapDevice{}
+	s := &Service{
+		logger:        zap.NewNop(),
+		adapterConfig: &config.AdapterConfig{Name: "test"},
+		sourceDevice:  device,
+		mutex:         &sync.RWMutex{},
+		sourceState:   newStateMachine(),
+		mqttState:     newStateMachine(),
+	}
+	assert.NoError(t, s.sourceState.Transition(types.StatusConnecting, ""))
+	assert.NoError(t, s.sourceState.Transition(types.StatusUP, ""))
+
+	wg := sync.WaitGroup{}
+	for index := 0; index < 10; index++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := s.send(directionToSource, types.NewMessage([]byte("send")), false)
+			assert.NoError(t, err)
+		}()
+		// queue consumer
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, s.writeToSource(types.NewMessage([]byte("queued"))))
+		}()
+	}
+	wg.Wait()
+	assert.False(t, device.overlapped.Load())
+}
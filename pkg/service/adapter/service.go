@@ -4,15 +4,20 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/mycontroller-org/2mqtt/pkg/capture"
+	"github.com/mycontroller-org/2mqtt/pkg/filter"
+	"github.com/mycontroller-org/2mqtt/pkg/journal"
+	"github.com/mycontroller-org/2mqtt/pkg/queue"
+	"github.com/mycontroller-org/2mqtt/pkg/service/metrics"
 	scheduler "github.com/mycontroller-org/2mqtt/pkg/service/scheduler"
 	"github.com/mycontroller-org/2mqtt/pkg/types"
 	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
 	contextTY "github.com/mycontroller-org/2mqtt/pkg/types/context"
 	devicePlugin "github.com/mycontroller-org/2mqtt/plugin/device"
 	providerPlugin "github.com/mycontroller-org/2mqtt/plugin/provider"
-	queue "github.com/mycontroller-org/server/v2/pkg/utils/queue"
 	"go.uber.org/zap"
 )
 
@@ -21,10 +26,29 @@ const (
 	SourceQueueSize       = 1000
 	MQTTQueueSize         = 1000
 	DefaultReconnectDelay = "30s"
+	DefaultDrainTimeout   = time.Second * 10
 
 	MqttDeviceName = "mqtt"
 )
 
+// device and direction names, used on logs and metrics
+const (
+	deviceSource      = "source"
+	deviceMqtt        = "mqtt"
+	directionToMqtt   = "to_mqtt"
+	directionToSource = "to_source"
+)
+
+// drop reasons
+const (
+	dropReasonDeviceDown = "device_down"
+	dropReasonBufferFull = "buffer_full"
+	dropReasonExpired    = "expired"
+	dropReasonQueueFull  = "queue_full"
+	dropReasonCoalesced  = "coalesced"
+	dropReasonShutdown   = "shutdown"
+)
+
 // Service component of the provider
 type Service struct {
 	ctx                context.Context
@@ -36,12 +60,33 @@ type Service struct {
 	mqttDevice         types.Device
 	sourceMessageQueue *queue.Queue
 	mqttMessageQueue   *queue.Queue
-	statusSource       types.State
-	statusMqtt         types.State
-	mutex              *sync.RWMutex
-	reconnectDelay     string
+	sourceBuffer       *messageBuffer
+	mqttBuffer         *messageBuffer
+	journal            *journal.Journal
+	capture            *capture.Writer
+	correlator         *correlator
+	toMqttFilter       *filter.Filter
+	toSourceFilter     *filter.Filter
+	toMqttPipeline     directionPipeline
+	toSourcePipeline   directionPipeline
+	sourceState        *stateMachine
+	mqttState          *stateMachine
+	mutex              *sync.RWMutex // guards the device instances, replaced on reconnect
+	intakeStopped      atomic.Bool   // set on stop, new messages from the devices will be dropped
+	journalReplay      atomic.Bool   // set to deliver the pending journal records
+	journalRetry       atomic.Bool   // set when a retry of the journal replay is scheduled
+	toSourceFormat     sync.Mutex    // serializes the formatter and the pipelines on to_source direction
+	toMqttFormat       sync.Mutex    // serializes the formatter and the pipelines on to_mqtt direction
+	sourceWrite        sync.Mutex    // serializes the writes to the source device
+	mqttWrite          sync.Mutex    // serializes the writes to the mqtt device
+	sourceBackoff      *backoff
+	mqttBackoff        *backoff
+	watchdog           *watchdog    // nil if the watchdog is disabled
+	sourceGeneration   atomic.Int64 // incremented on each source device instance
+	mqttGeneration     atomic.Int64 // incremented on each mqtt device instance
 	sourceID           string
 	mqttID             string
+	watchdogID         string
 }
 
 // NewService creates brand new Service
@@ -70,19 +115,101 @@ func NewService(ctx context.Context, adapterCfg *config.AdapterConfig) (*Service
 		adapterConfig: adapterCfg,
 		provider:      provider,
 		mutex:         &sync.RWMutex{},
+		sourceState:   newStateMachine(),
+		mqttState:     newStateMachine(),
 		sourceID:      fmt.Sprintf("%s_adapter_source", adapterCfg.Name),
 		mqttID:        fmt.Sprintf("%s_adapter_mqtt", adapterCfg.Name),
+		watchdogID:    fmt.Sprintf("%s_adapter_watchdog", adapterCfg.Name),
+	}
+	// message queues, source queue holds the messages to source device
+	sourceQueueSize := adapterCfg.Queue.ToSource.Capacity
+	if sourceQueueSize <= 0 {
+		sourceQueueSize = SourceQueueSize
+	}
+	s.sourceMessageQueue, err = queue.New(s.sourceID, sourceQueueSize, adapterCfg.Queue.ToSource.OverflowPolicy)
+	if err != nil {
+		logger.Error("error on creating source queue", zap.String("name", adapterCfg.Name), zap.Error(err))
+		return nil, err
+	}
+	mqttQueueSize := adapterCfg.Queue.ToMQTT.Capacity
+	if mqttQueueSize <= 0 {
+		mqttQueueSize = MQTTQueueSize
+	}
+	s.mqttMessageQueue, err = queue.New(s.mqttID, mqttQueueSize, adapterCfg.Queue.ToMQTT.OverflowPolicy)
+	if err != nil {
+		logger.Error("error on creating mqtt queue", zap.String("name", adapterCfg.Name), zap.Error(err))
+		return nil, err
 	}
-	s.sourceMessageQueue = queue.New(logger, s.sourceID, SourceQueueSize, func(item interface{}) {}, 1)
-	s.mqttMessageQueue = queue.New(logger, s.mqttID, MQTTQueueSize, func(item interface{}) {}, 1)
 
-	// update reconnectDelay
-	_, err = time.ParseDuration(adapterCfg.ReconnectDelay)
+	// store and forward buffers
+	if adapterCfg.Buffer.Enabled {
+		s.sourceBuffer = newMessageBuffer(adapterCfg.Buffer)
+		s.mqttBuffer = newMessageBuffer(adapterCfg.Buffer)
+	}
+
+	// filters, pipelines and correlation are created before opening the journal and the capture, not to leak them on errors
+	// message filters
+	if s.toMqttFilter, err = filter.New(adapterCfg.Filters.ToMQTT); err != nil {
+		logger.Error("error on to_mqtt filters", zap.String("name", adapterCfg.Name), zap.Error(err))
+		return nil, err
+	}
+	if s.toSourceFilter, err = filter.New(adapterCfg.Filters.ToSource); err != nil {
+		logger.Error("error on to_source filters", zap.String("name", adapterCfg.Name), zap.Error(err))
+		return nil, err
+	}
+
+	// transformation pipelines
+	if s.toMqttPipeline, err = newDirectionPipeline(s.logger, adapterCfg.Pipeline.ToMQTT); err != nil {
+		logger.Error("error on to_mqtt pipeline", zap.String("name", adapterCfg.Name), zap.Error(err))
+		return nil, err
+	}
+	if s.toSourcePipeline, err = newDirectionPipeline(s.logger, adapterCfg.Pipeline.ToSource); err != nil {
+		logger.Error("error on to_source pipeline", zap.String("name", adapterCfg.Name), zap.Error(err))
+		return nil, err
+	}
+
+	// request and response correlation
+	if adapterCfg.RequestResponse.Enabled {
+		_correlator, err := newCorrelator(s.logger, adapterCfg.RequestResponse, s.publishResponse)
+		if err != nil {
+			logger.Error("error on request response config", zap.String("name", adapterCfg.Name), zap.Error(err))
+			return nil, err
+		}
+		s.correlator = _correlator
+	}
+
+	// durable journal, keeps the source messages until those are published to mqtt
+	if adapterCfg.Journal.Enabled {
+		_journal, err := openJournal(ctx, logger, adapterCfg)
+		if err != nil {
+			logger.Error("error on opening journal", zap.String("name", adapterCfg.Name), zap.Error(err))
+			return nil, err
+		}
+		s.journal = _journal
+	}
+
+	// traffic capture
+	if adapterCfg.Capture.Enabled {
+		_capture, err := openCapture(ctx, logger, adapterCfg)
+		if err != nil {
+			logger.Error("error on opening capture", zap.String("name", adapterCfg.Name), zap.Error(err))
+			s.closeJournal()
+			return nil, err
+		}
+		s.capture = _capture
+	}
+
+	// update reconnectDelay, used as initial delay when the reconnect policy is not defined
+	reconnectDelay, err := time.ParseDuration(adapterCfg.ReconnectDelay)
 	if err != nil {
 		logger.Info("error on parsing reconnect delay, running with default", zap.String("reconnectDelay", adapterCfg.ReconnectDelay), zap.String("default", DefaultReconnectDelay), zap.Error(err))
-		s.reconnectDelay = DefaultReconnectDelay
-	} else {
-		s.reconnectDelay = adapterCfg.ReconnectDelay
+		reconnectDelay, _ = time.ParseDuration(DefaultReconnectDelay)
+	}
+	s.sourceBackoff = newBackoff(adapterCfg.ReconnectPolicy, reconnectDelay)
+	s.mqttBackoff = newBackoff(adapterCfg.ReconnectPolicy, reconnectDelay)
+
+	if adapterCfg.Watchdog.Enabled {
+		s.watchdog = newWatchdog(adapterCfg.Watchdog)
 	}
 
 	return s, nil
@@ -90,23 +217,41 @@ func NewService(ctx context.Context, adapterCfg *config.AdapterConfig) (*Service
 
 // Start starts a adapter service
 func (s *Service) Start() {
-	s.reconnectMqttDevice()
-	s.reconnectSourceDevice()
+	s.connectMqttDevice()
+	s.connectSourceDevice()
 
-	s.sourceMessageQueue.Queue.StartConsumers(1, s.sourceMessageProcessor)
-	s.mqttMessageQueue.Queue.StartConsumers(1, s.mqttMessageProcessor)
+	s.sourceMessageQueue.StartConsumers(1, s.sourceMessageProcessor)
+	s.mqttMessageQueue.StartConsumers(1, s.mqttMessageProcessor)
+	// deliver the pending messages from the previous run, delivered once mqtt is up
+	s.requestJournalReplay()
+
+	s.startWatchdog()
+	metrics.RegisterCollector(s.adapterConfig.Name, s.collectMetrics)
 }
 
-// Start stops a adapter service
-func (s *Service) Stop() {
-	if s.sourceDevice != nil {
-		err := s.sourceDevice.Close()
+// Stop stops a adapter service, waits for the queued messages until the drain timeout
+func (s *Service) Stop(drainTimeout time.Duration) {
+	metrics.UnregisterCollector(s.adapterConfig.Name)
+
+	// stop intake and deliver the queued messages, before closing the connections
+	s.intakeStopped.Store(true)
+	s.drainQueues(drainTimeout)
+
+	// status reports and scheduled reconnects are ignored on stopped state
+	s.scheduler.Unschedule(s.watchdogID)
+	s.scheduler.Unschedule(s.sourceID)
+	s.scheduler.Unschedule(s.mqttID)
+	s.transition(deviceSource, types.StatusStopped, "adapter stopped")
+	s.transition(deviceMqtt, types.StatusStopped, "adapter stopped")
+
+	if sourceDevice := s.getSourceDevice(); sourceDevice != nil {
+		err := sourceDevice.Close()
 		if err != nil {
 			s.logger.Error("error on closing a source connection", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Error(err))
 		}
 	}
-	if s.mqttDevice != nil {
-		err := s.mqttDevice.Close()
+	if mqttDevice := s.getMqttDevice(); mqttDevice != nil {
+		err := mqttDevice.Close()
 		if err != nil {
 			s.logger.Error("error on closing a mqtt connection", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Error(err))
 		}
@@ -114,180 +259,531 @@ func (s *Service) Stop() {
 
 	// close message queues
 	if s.mqttMessageQueue != nil {
-		s.mqttMessageQueue.Queue.Stop()
+		s.mqttMessageQueue.Stop()
 	}
 	if s.sourceMessageQueue != nil {
-		s.sourceMessageQueue.Queue.Stop()
+		s.sourceMessageQueue.Stop()
 	}
+
+	if s.correlator != nil {
+		s.correlator.Close()
+	}
+	s.closeJournal()
+	s.closeCapture()
 }
 
-func (s *Service) mqttMessageProcessor(item interface{}) {
-	if item == nil {
+// drainQueues waits until the queued and the buffered messages written to the devices or the drain timeout.
+// buffered messages of an unavailable device can not be delivered, reported as dropped
+func (s *Service) drainQueues(drainTimeout time.Duration) {
+	s.requestFlush(s.sourceMessageQueue, s.sourceBuffer)
+	s.requestFlush(s.mqttMessageQueue, s.mqttBuffer)
+
+	wg := sync.WaitGroup{}
+	for _, messageQueue := range []*queue.Queue{s.sourceMessageQueue, s.mqttMessageQueue} {
+		if messageQueue == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(messageQueue *queue.Queue) {
+			defer wg.Done()
+			if !messageQueue.Drain(drainTimeout) {
+				s.logger.Warn("queue not drained within the timeout, remaining messages will be dropped", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("queue", messageQueue.Name()), zap.Int("remaining", messageQueue.Size()), zap.String("drainTimeout", drainTimeout.String()))
+			}
+		}(messageQueue)
+	}
+	wg.Wait()
+
+	s.dropBuffered(s.sourceBuffer, deviceSource)
+	s.dropBuffered(s.mqttBuffer, deviceMqtt)
+}
+
+// dropBuffered removes the messages not delivered from the buffer
+func (s *Service) dropBuffered(buffer *messageBuffer, deviceName string) {
+	if buffer == nil {
 		return
 	}
-	message, ok := item.(*types.Message)
-	if !ok {
-		s.logger.Error("error on cast a message", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Any("message", item))
+	dropped := buffer.Clear()
+	if dropped == 0 {
 		return
 	}
+	metrics.Add(metrics.MessagesDropped, float64(dropped), s.adapterConfig.Name, deviceName, dropReasonShutdown)
+	s.logger.Warn("device is not available, buffered messages dropped on stop", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("device", deviceName), zap.Int("dropped", dropped))
+}
+
+func (s *Service) mqttMessageProcessor(item interface{}) {
+	if s.journalReplay.CompareAndSwap(true, false) {
+		s.replayJournal()
+	}
+	switch item.(type) {
+	case flushRequest:
+		s.flushBuffer(s.mqttBuffer, deviceMqtt, s.isMqttUP, s.writeToMqtt)
+		return
+	case journalReplayRequest:
+		return
+	}
+	message := s.toMessage(item)
 	if message == nil {
-		s.logger.Error("message can not be nil", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider))
 		return
 	}
-
-	if s.statusMqtt.Status == types.StatusUP {
-		message.Others.Set(types.KeyMqttQoS, int(s.adapterConfig.MQTT.GetInt64(types.KeyMqttQoS)), nil)
-		err := s.mqttDevice.Write(message)
-		if err != nil {
-			s.logger.Error("error on writing a message to mqtt", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Error(err))
-		}
+	if sequence, ok := journalSequence(message); ok && s.journal != nil {
+		s.deliverJournaled(message, sequence)
+		return
 	}
-	// else {
-	// TODO: this message will be dropped, needs to handle this message
-	// }
+	if s.adapterConfig.DryRun.Enabled {
+		s.dryRun(directionToMqtt, message)
+		return
+	}
+	s.processMessage(message, s.mqttBuffer, deviceMqtt, s.isMqttUP, s.writeToMqtt)
 }
 
 func (s *Service) sourceMessageProcessor(item interface{}) {
-	if item == nil {
+	if _, ok := item.(flushRequest); ok {
+		s.flushBuffer(s.sourceBuffer, deviceSource, s.isSourceUP, s.writeToSource)
 		return
 	}
+	message := s.toMessage(item)
+	if message == nil {
+		return
+	}
+	if s.adapterConfig.DryRun.Enabled {
+		s.dryRun(directionToSource, message)
+		return
+	}
+	s.processMessage(message, s.sourceBuffer, deviceSource, s.isSourceUP, s.writeToSource)
+}
+
+func (s *Service) toMessage(item interface{}) *types.Message {
+	if item == nil {
+		return nil
+	}
 	message, ok := item.(*types.Message)
 	if !ok {
 		s.logger.Error("error on cast a message", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Any("message", item))
-		return
+		return nil
 	}
 	if message == nil {
 		s.logger.Error("message can not be nil", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider))
+		return nil
+	}
+	return message
+}
+
+// processMessage writes the message to the device, if the device is not available keeps it on the buffer
+func (s *Service) processMessage(message *types.Message, buffer *messageBuffer, deviceName string, isUP func() bool, writeFunc func(*types.Message) error) {
+	if buffer == nil {
+		if isUP() {
+			_ = writeFunc(message)
+		} else {
+			metrics.Inc(metrics.MessagesDropped, s.adapterConfig.Name, deviceName, dropReasonDeviceDown)
+			s.logger.Warn("device is not available, message dropped", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("device", deviceName), zap.String("message", message.ToString()))
+		}
 		return
 	}
-	if s.statusSource.Status == types.StatusUP {
-		s.logger.Debug("posting a message to source device", zap.String("message", message.ToString()), zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider))
-		err := s.sourceDevice.Write(message)
-		if err != nil {
-			s.logger.Error("error on writing a message to source device", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Error(err))
+
+	// keep the order, deliver the buffered messages first
+	if isUP() && buffer.Len() > 0 {
+		s.flushBuffer(buffer, deviceName, isUP, writeFunc)
+	}
+
+	if isUP() && buffer.Len() == 0 {
+		if err := writeFunc(message); err == nil {
+			return
+		}
+	}
+
+	dropped := buffer.Add(message)
+	if dropped > 0 {
+		metrics.Add(metrics.MessagesDropped, float64(dropped), s.adapterConfig.Name, deviceName, dropReasonBufferFull)
+		s.logger.Warn("buffer limit reached, messages dropped", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("device", deviceName), zap.Int("dropped", dropped))
+	}
+	s.logger.Debug("device is not available, message buffered", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("device", deviceName), zap.Int("buffered", buffer.Len()))
+}
+
+// flushBuffer writes all the buffered messages in order, stops on the first failure
+func (s *Service) flushBuffer(buffer *messageBuffer, deviceName string, isUP func() bool, writeFunc func(*types.Message) error) {
+	if buffer == nil {
+		return
+	}
+	flushed := 0
+	for isUP() {
+		message, dropped := buffer.Peek()
+		if dropped > 0 {
+			metrics.Add(metrics.MessagesDropped, float64(dropped), s.adapterConfig.Name, deviceName, dropReasonExpired)
+			s.logger.Warn("buffered messages expired, messages dropped", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("device", deviceName), zap.Int("dropped", dropped))
+		}
+		if message == nil {
+			break
+		}
+		if err := writeFunc(message); err != nil {
+			break
+		}
+		buffer.Remove(message)
+		flushed++
+	}
+	if flushed > 0 {
+		s.logger.Info("flushed buffered messages", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("device", deviceName), zap.Int("flushed", flushed), zap.Int("remaining", buffer.Len()))
+	}
+}
+
+func (s *Service) writeToMqtt(message *types.Message) error {
+	message.Others.Set(types.KeyMqttQoS, int(s.adapterConfig.MQTT.GetInt64(types.KeyMqttQoS)), nil)
+	s.mqttWrite.Lock()
+	err := s.getMqttDevice().Write(message)
+	s.mqttWrite.Unlock()
+	if err != nil {
+		metrics.Inc(metrics.WriteErrors, s.adapterConfig.Name, deviceMqtt)
+		s.logger.Error("error on writing a message to mqtt", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Error(err))
+		return err
+	}
+	metrics.Inc(metrics.MessagesSent, s.adapterConfig.Name, deviceMqtt)
+	return nil
+}
+
+func (s *Service) writeToSource(message *types.Message) error {
+	s.logger.Debug("posting a message to source device", zap.String("message", message.ToString()), zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider))
+	// registered before writing, the reply may arrive before the write returns
+	var request *pendingRequest
+	if s.correlator != nil {
+		request = s.correlator.Register(message)
+	}
+	err := s.writeSourceDevice(message)
+	if err != nil {
+		if s.correlator != nil {
+			s.correlator.Unregister(request)
 		}
+		metrics.Inc(metrics.WriteErrors, s.adapterConfig.Name, deviceSource)
+		s.logger.Error("error on writing a message to source device", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Error(err))
+		return err
 	}
-	// else {
-	// TODO: this message will be dropped, needs to handle this message
-	// }
+	metrics.Inc(metrics.MessagesSent, s.adapterConfig.Name, deviceSource)
+	return nil
+}
+
+// writeSourceDevice writes to the source device, the frames from the queue consumer, send and the probe do not interleave
+func (s *Service) writeSourceDevice(message *types.Message) error {
+	s.sourceWrite.Lock()
+	defer s.sourceWrite.Unlock()
+	return s.getSourceDevice().Write(message)
+}
+
+func (s *Service) isMqttUP() bool {
+	return s.mqttState.Is(types.StatusUP)
+}
+
+func (s *Service) isSourceUP() bool {
+	return s.sourceState.Is(types.StatusUP)
 }
 
 func (s *Service) onMqttMessage(message *types.Message) {
+	metrics.Inc(metrics.MessagesReceived, s.adapterConfig.Name, deviceMqtt)
+	if s.intakeStopped.Load() {
+		metrics.Inc(metrics.MessagesDropped, s.adapterConfig.Name, deviceSource, dropReasonShutdown)
+		return
+	}
 	s.logger.Debug("received a mqtt message", zap.String("message", message.ToString()), zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider))
-	formattedMsg, err := s.provider.ToSourceMessage(message)
+	s.captureMessage(directionToSource, capture.StageRaw, message)
+	if s.filterMessage(directionToSource, s.toSourceFilter.CheckTopic(message.Others.GetString(types.KeyMqttTopic)), message) ||
+		s.filterMessage(directionToSource, s.toSourceFilter.CheckPayload(message.Data), message) {
+		return
+	}
+	var request *types.Message
+	if s.correlator != nil {
+		var err error
+		if request, err = s.correlator.ToRequest(message); err != nil {
+			s.logger.Error("invalid request, rejected", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("message", message.ToString()), zap.Error(err))
+			return
+		}
+		if request != nil {
+			message = request
+		}
+	}
+	formattedMsg, err := s.formatMessage(directionToSource, message)
 	if err != nil {
-		s.logger.Error("error on formatting to source type", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Error(err))
 		return
 	}
-	s.sourceMessageQueue.Produce(formattedMsg)
+	// formatter may not keep the others, carry the correlation details
+	if request != nil && formattedMsg != nil {
+		if formattedMsg.Others == nil {
+			formattedMsg.Others = make(map[string]interface{})
+		}
+		formattedMsg.Others.Set(types.KeyCorrelationID, request.Others.Get(types.KeyCorrelationID), nil)
+		formattedMsg.Others.Set(types.KeyResponseTopic, request.Others.Get(types.KeyResponseTopic), nil)
+	}
+	s.captureMessage(directionToSource, capture.StageFormatted, formattedMsg)
+	s.produce(s.sourceMessageQueue, formattedMsg, deviceSource)
 }
 
 func (s *Service) onSourceMessage(message *types.Message) {
+	metrics.Inc(metrics.MessagesReceived, s.adapterConfig.Name, deviceSource)
+	if s.watchdog != nil {
+		s.watchdog.received(time.Now())
+	}
+	if s.intakeStopped.Load() {
+		metrics.Inc(metrics.MessagesDropped, s.adapterConfig.Name, deviceMqtt, dropReasonShutdown)
+		return
+	}
 	s.logger.Debug("received a message from source device", zap.String("message", message.ToString()), zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider))
-	formattedMsg, err := s.provider.ToMQTTMessage(message)
-	if err != nil {
-		s.logger.Error("error on formatting to mqtt", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Error(err))
+	s.captureMessage(directionToMqtt, capture.StageRaw, message)
+	if s.filterMessage(directionToMqtt, s.toMqttFilter.CheckPayload(message.Data), message) {
 		return
 	}
-	s.mqttMessageQueue.Produce(formattedMsg)
-}
-
-func (s *Service) onMqttStatus(state *types.State) {
-	if state == nil {
+	if s.correlator != nil {
+		s.correlator.Match(message)
+	}
+	formattedMsg, err := s.formatMessage(directionToMqtt, message)
+	if err != nil {
 		return
 	}
-	s.statusMqtt = *state
-
-	if state.Status == types.StatusUP {
+	// topic is available after formatting
+	if formattedMsg != nil && s.filterMessage(directionToMqtt, s.toMqttFilter.CheckTopic(queueKey(formattedMsg)), formattedMsg) {
 		return
 	}
+	s.captureMessage(directionToMqtt, capture.StageFormatted, formattedMsg)
+	s.appendJournal(formattedMsg)
+	s.produce(s.mqttMessageQueue, formattedMsg, deviceMqtt)
+}
 
-	// schedule a job for reconnect
-	err := s.scheduler.Schedule(s.mqttID, s.reconnectDelay, s.reconnectMqttDevice)
-	if err != nil {
-		s.logger.Error("error on configuring a schedule", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("id", s.mqttID), zap.Error(err))
+// filterMessage returns true if the reason is not empty, counts and logs the filtered message
+func (s *Service) filterMessage(direction, reason string, message *types.Message) bool {
+	if reason == "" {
+		return false
 	}
+	metrics.Inc(metrics.MessagesFiltered, s.adapterConfig.Name, direction, reason)
+	s.logger.Debug("message filtered", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("direction", direction), zap.String("reason", reason), zap.String("message", message.ToString()))
+	return true
+}
 
+func (s *Service) onMqttStatus(state *types.State) {
+	if state == nil {
+		return
+	}
+	s.onDeviceStatus(deviceMqtt, state)
 }
 
 func (s *Service) onSourceStatus(state *types.State) {
 	if state == nil {
 		return
 	}
-	s.statusSource = *state
+	s.onDeviceStatus(deviceSource, state)
+}
 
+// onDeviceStatus updates the device state from the status reported by the device.
+// reports from a stopped or already reconnecting device are ignored
+func (s *Service) onDeviceStatus(deviceName string, state *types.State) {
 	if state.Status == types.StatusUP {
+		s.deviceUP(deviceName)
 		return
 	}
+	if s.transition(deviceName, types.StatusDisconnected, state.Message) {
+		s.scheduleReconnect(deviceName)
+	}
+}
 
-	// schedule a job for reconnect
-	err := s.scheduler.Schedule(s.sourceID, s.reconnectDelay, s.reconnectSourceDevice)
-	if err != nil {
-		s.logger.Error("error on configuring a schedule", zap.String("id", s.sourceID), zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Error(err))
+// deviceUP moves the device to up state, resets the reconnect attempts and delivers the buffered messages
+func (s *Service) deviceUP(deviceName string) {
+	if !s.transition(deviceName, types.StatusUP, "connected") {
+		return
 	}
+	if deviceName == deviceMqtt {
+		s.mqttBackoff.Reset()
+		s.logger.Info("connected to the mqtt broker", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider))
+		s.publishSourceStatus()
+		s.requestJournalReplay()
+		s.requestFlush(s.mqttMessageQueue, s.mqttBuffer)
+		return
+	}
+	s.sourceBackoff.Reset()
+	s.logger.Info("connected to the source device", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider))
+	s.requestFlush(s.sourceMessageQueue, s.sourceBuffer)
+}
 
+// transition updates the device state, returns false if the transition is not allowed.
+// source state changes are published on the status topic
+func (s *Service) transition(deviceName, status, reason string) bool {
+	sm := s.mqttState
+	if deviceName == deviceSource {
+		sm = s.sourceState
+	}
+	if err := sm.Transition(status, reason); err != nil {
+		s.logger.Debug("device state not updated", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("device", deviceName), zap.String("reason", reason), zap.Error(err))
+		return false
+	}
+	s.logger.Debug("device state updated", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("device", deviceName), zap.String("status", status), zap.String("reason", reason))
+	if deviceName == deviceSource {
+		s.publishSourceStatus()
+	}
+	return true
 }
 
+// reconnectMqttDevice triggered by the reconnect schedule
 func (s *Service) reconnectMqttDevice() {
 	s.scheduler.Unschedule(s.mqttID)
-	if s.statusMqtt.Status == types.StatusUP {
+	if !s.mqttState.Is(types.StatusReconnecting) {
 		return
 	}
+	metrics.Inc(metrics.ReconnectAttempts, s.adapterConfig.Name, deviceMqtt)
+	s.connectMqttDevice()
+}
 
-	if s.mqttDevice != nil {
-		err := s.mqttDevice.Close()
+// reconnectSourceDevice triggered by the reconnect schedule
+func (s *Service) reconnectSourceDevice() {
+	s.scheduler.Unschedule(s.sourceID)
+	if !s.sourceState.Is(types.StatusReconnecting) {
+		return
+	}
+	metrics.Inc(metrics.ReconnectAttempts, s.adapterConfig.Name, deviceSource)
+	s.connectSourceDevice()
+}
+
+// connectMqttDevice closes the existing connection and creates a new one.
+// mqtt device reports up, once the connection established and the topics subscribed
+func (s *Service) connectMqttDevice() {
+	// close before moving to connecting state, status reports on closing are ignored
+	if mqttDevice := s.getMqttDevice(); mqttDevice != nil {
+		err := mqttDevice.Close()
 		if err != nil {
 			s.logger.Error("error on colsing a mqtt connection", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Error(err))
 		}
 	}
-
-	mqttDevice, err := devicePlugin.Create(s.ctx, MqttDeviceName, s.adapterConfig.Name, s.adapterConfig.MQTT, s.onMqttMessage, s.onMqttStatus)
-	if err == nil {
-		s.mqttDevice = mqttDevice
-		// update status UP
-		s.statusMqtt = types.State{
-			Status: types.StatusUP,
-			Since:  time.Now(),
-		}
-		s.logger.Info("connected to the mqtt broker", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider))
+	if !s.transition(deviceMqtt, types.StatusConnecting, "") {
 		return
 	}
-	s.logger.Error("error on getting mqtt connection", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("reconnectDelay", s.reconnectDelay), zap.Error(err))
-	// schedule a job for reconnect
-	err = s.scheduler.Schedule(s.mqttID, s.reconnectDelay, s.reconnectMqttDevice)
-	if err != nil {
-		s.logger.Error("error on configuring a schedule", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("id", s.mqttID), zap.Error(err))
-	}
-}
 
-func (s *Service) reconnectSourceDevice() {
-	s.scheduler.Unschedule(s.sourceID)
-	if s.statusSource.Status == types.StatusUP {
+	// the device may report up before create returns, reports are held until the device assigned
+	gate := newStatusGate(&s.mqttGeneration, s.onMqttStatus)
+	mqttDevice, err := devicePlugin.Create(s.ctx, MqttDeviceName, s.adapterConfig.Name, s.adapterConfig.MQTT, s.onMqttMessage, gate.Report)
+	if err != nil {
+		s.logger.Error("error on getting mqtt connection", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Error(err))
+		if s.transition(deviceMqtt, types.StatusDisconnected, err.Error()) {
+			s.scheduleReconnect(deviceMqtt)
+		}
 		return
 	}
+	s.mutex.Lock()
+	s.mqttDevice = mqttDevice
+	s.mutex.Unlock()
+	gate.Open()
+}
 
-	if s.sourceDevice != nil {
-		err := s.sourceDevice.Close()
+// connectSourceDevice closes the existing connection and creates a new one.
+// source devices report only the failures, marked as up once created
+func (s *Service) connectSourceDevice() {
+	// close before moving to connecting state, status reports on closing are ignored
+	if sourceDevice := s.getSourceDevice(); sourceDevice != nil {
+		err := sourceDevice.Close()
 		if err != nil {
 			s.logger.Error("error on closing a source connection", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Error(err))
 		}
 	}
+	if !s.transition(deviceSource, types.StatusConnecting, "") {
+		return
+	}
 
-	sourceDevice, err := devicePlugin.Create(s.ctx, s.adapterConfig.Source.GetString(types.KeyType), s.adapterConfig.Name, s.adapterConfig.Source, s.onSourceMessage, s.onSourceStatus)
-	if err == nil {
-		s.sourceDevice = sourceDevice
-		// update status UP
-		s.statusSource = types.State{
-			Status: types.StatusUP,
-			Since:  time.Now(),
+	gate := newStatusGate(&s.sourceGeneration, s.onSourceStatus)
+	sourceDevice, err := devicePlugin.Create(s.ctx, s.adapterConfig.Source.GetString(types.KeyType), s.adapterConfig.Name, s.adapterConfig.Source, s.onSourceMessage, gate.Report)
+	if err != nil {
+		s.logger.Error("error on getting source connection", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Error(err))
+		if s.transition(deviceSource, types.StatusDisconnected, err.Error()) {
+			s.scheduleReconnect(deviceSource)
 		}
-		s.logger.Info("connected to the source device", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider))
 		return
 	}
-	s.logger.Error("error on getting source connection", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("reconnectDelay", s.reconnectDelay), zap.Error(err))
-	// schedule a job for reconnect
-	err = s.scheduler.Schedule(s.sourceID, s.reconnectDelay, s.reconnectSourceDevice)
+	s.mutex.Lock()
+	s.sourceDevice = sourceDevice
+	s.mutex.Unlock()
+	s.deviceUP(deviceSource)
+	// failures reported while creating, applied after the up
+	gate.Open()
+}
+
+func (s *Service) getMqttDevice() types.Device {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.mqttDevice
+}
+
+func (s *Service) getSourceDevice() types.Device {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.sourceDevice
+}
+
+// scheduleReconnect schedules a reconnect job with the next backoff delay.
+// marks the device as failed, if the reconnect attempts exhausted
+func (s *Service) scheduleReconnect(deviceName string) {
+	scheduleID, _backoff, reconnectFunc := s.mqttID, s.mqttBackoff, s.reconnectMqttDevice
+	if deviceName == deviceSource {
+		scheduleID, _backoff, reconnectFunc = s.sourceID, s.sourceBackoff, s.reconnectSourceDevice
+	}
+
+	delay, ok := _backoff.Next()
+	if !ok {
+		s.scheduler.Unschedule(scheduleID)
+		s.transition(deviceName, types.StatusFailed, fmt.Sprintf("reconnect attempts exhausted, attempts:%d", _backoff.Status().Attempts))
+		s.logger.Error("reconnect attempts exhausted, marked as failed", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("device", deviceName))
+		return
+	}
+
+	if !s.transition(deviceName, types.StatusReconnecting, fmt.Sprintf("retry in %s", delay)) {
+		return
+	}
+	s.logger.Info("scheduling a reconnect", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("device", deviceName), zap.String("delay", delay.String()))
+	// the schedule triggers the reconnect function, which removes the schedule on the first run
+	err := s.scheduler.Schedule(scheduleID, delay.String(), reconnectFunc)
 	if err != nil {
-		s.logger.Error("error on configuring a schedule", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("id", s.sourceID), zap.Error(err))
+		s.logger.Error("error on configuring a schedule", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("id", scheduleID), zap.Error(err))
+	}
+}
+
+// requestFlush posts a flush request to the queue consumer, if there is a buffered message
+func (s *Service) requestFlush(messageQueue *queue.Queue, buffer *messageBuffer) {
+	if buffer == nil || buffer.Len() == 0 {
+		return
+	}
+	messageQueue.Signal(flushRequest{})
+}
+
+// produce posts the message to the queue, reports if the queue is full
+func (s *Service) produce(messageQueue *queue.Queue, message *types.Message, deviceName string) {
+	dropped := messageQueue.Produce(queueKey(message), message)
+	if dropped == 0 {
+		return
+	}
+	reason := dropReasonQueueFull
+	if messageQueue.Policy() == queue.OverflowCoalesce {
+		reason = dropReasonCoalesced
+	}
+	metrics.Add(metrics.MessagesDropped, float64(dropped), s.adapterConfig.Name, deviceName, reason)
+	s.logger.Warn("queue overflow", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("queue", messageQueue.Name()), zap.String("overflowPolicy", messageQueue.Policy()), zap.Int("dropped", dropped))
+}
+
+// queueKey returns the mqtt topic of the message, used to coalesce the messages
+func queueKey(message *types.Message) string {
+	if message == nil {
+		return ""
+	}
+	if topic := message.Others.GetString(types.KeyMqttAbsoluteTopic); topic != "" {
+		return topic
+	}
+	return message.Others.GetString(types.KeyMqttTopic)
+}
+
+// collectMetrics returns the queue depth and device state
+func (s *Service) collectMetrics() []metrics.Sample {
+	name := s.adapterConfig.Name
+	samples := []metrics.Sample{
+		{Name: metrics.QueueDepth, LabelValues: []string{name, deviceSource}, Value: float64(s.sourceMessageQueue.Size())},
+		{Name: metrics.QueueDepth, LabelValues: []string{name, deviceMqtt}, Value: float64(s.mqttMessageQueue.Size())},
+	}
+	devices := map[string]string{deviceSource: s.sourceState.State().Status, deviceMqtt: s.mqttState.State().Status}
+	for device, currentStatus := range devices {
+		for _, status := range deviceStates {
+			value := float64(0)
+			if status == currentStatus {
+				value = 1
+			}
+			samples = append(samples, metrics.Sample{Name: metrics.DeviceState, LabelValues: []string{name, device, status}, Value: value})
+		}
 	}
+	return samples
 }
@@ -0,0 +1,159 @@
+package adapter
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mycontroller-org/2mqtt/pkg/types"
+)
+
+// number of transitions kept on the history of a device
+const stateHistorySize = 20
+
+// device lifecycle states, in the order reported on the metrics
+var deviceStates = []string{
+	types.StatusStopped,
+	types.StatusConnecting,
+	types.StatusUP,
+	types.StatusDisconnected,
+	types.StatusReconnecting,
+	types.StatusFailed,
+}
+
+// allowed transitions from a state, other transitions are ignored
+var allowedTransitions = map[string][]string{
+	types.StatusStopped:      {types.StatusConnecting},
+	types.StatusConnecting:   {types.StatusUP, types.StatusDisconnected, types.StatusStopped},
+	types.StatusUP:           {types.StatusDisconnected, types.StatusStopped},
+	types.StatusDisconnected: {types.StatusUP, types.StatusReconnecting, types.StatusFailed, types.StatusStopped},
+	types.StatusReconnecting: {types.StatusConnecting, types.StatusUP, types.StatusStopped},
+	types.StatusFailed:       {types.StatusStopped},
+}
+
+// Transition of a device state
+type Transition struct {
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Reason string    `json:"reason,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// stateMachine holds the device state and the recent transitions, safe for the concurrent use
+type stateMachine struct {
+	mutex   sync.RWMutex
+	state   types.State
+	history []Transition
+}
+
+func newStateMachine() *stateMachine {
+	return &stateMachine{
+		state:   types.State{Status: types.StatusStopped, Since: time.Now()},
+		history: make([]Transition, 0, stateHistorySize),
+	}
+}
+
+// Transition moves to the status, returns error if the transition is not allowed from the current status
+func (sm *stateMachine) Transition(status, reason string) error {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	from := sm.state.Status
+	if !isAllowed(from, status) {
+		return fmt.Errorf("transition not allowed, from:%s, to:%s", from, status)
+	}
+
+	now := time.Now()
+	sm.state = types.State{Status: status, Message: reason, Since: now}
+	if len(sm.history) == stateHistorySize {
+		sm.history = append(sm.history[:0], sm.history[1:]...)
+	}
+	sm.history = append(sm.history, Transition{From: from, To: status, Reason: reason, Time: now})
+	return nil
+}
+
+// State returns the current state
+func (sm *stateMachine) State() types.State {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+	return sm.state
+}
+
+// Is returns true, if the current status is the given status
+func (sm *stateMachine) Is(status string) bool {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+	return sm.state.Status == status
+}
+
+// History returns the recent transitions, oldest first
+func (sm *stateMachine) History() []Transition {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+	return append([]Transition{}, sm.history...)
+}
+
+func isAllowed(from, to string) bool {
+	for _, allowed := range allowedTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// statusGate delivers the status reports of a device instance.
+// reports are held until the instance is assigned on the service and dropped once the instance is replaced
+type statusGate struct {
+	mutex      sync.Mutex
+	generation *atomic.Int64 // incremented on each device instance
+	current    int64
+	opened     bool
+	held       []*types.State
+	statusFunc func(*types.State)
+}
+
+func newStatusGate(generation *atomic.Int64, statusFunc func(*types.State)) *statusGate {
+	return &statusGate{
+		generation: generation,
+		current:    generation.Add(1),
+		statusFunc: statusFunc,
+	}
+}
+
+// Report is supplied to the device as status function
+func (g *statusGate) Report(state *types.State) {
+	g.mutex.Lock()
+	if !g.opened {
+		g.held = append(g.held, state)
+		g.mutex.Unlock()
+		return
+	}
+	g.mutex.Unlock()
+	g.deliver(state)
+}
+
+// Open delivers the held reports in order, later reports are delivered immediately
+func (g *statusGate) Open() {
+	for {
+		g.mutex.Lock()
+		held := g.held
+		g.held = nil
+		if len(held) == 0 {
+			g.opened = true
+			g.mutex.Unlock()
+			return
+		}
+		g.mutex.Unlock()
+		for _, state := range held {
+			g.deliver(state)
+		}
+	}
+}
+
+func (g *statusGate) deliver(state *types.State) {
+	if g.generation.Load() == g.current {
+		g.statusFunc(state)
+	}
+}
@@ -0,0 +1,100 @@
+package adapter
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mycontroller-org/2mqtt/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateMachine(t *testing.T) {
+	tests := []struct {
+		name        string
+		transitions []string
+		expected    []bool
+		final       string
+	}{
+		{
+			name:        "connect",
+			transitions: []string{types.StatusConnecting, types.StatusUP},
+			expected:    []bool{true, true},
+			final:       types.StatusUP,
+		},
+		{
+			name:        "reconnect",
+			transitions: []string{types.StatusConnecting, types.StatusUP, types.StatusDisconnected, types.StatusReconnecting, types.StatusConnecting, types.StatusUP},
+			expected:    []bool{true, true, true, true, true, true},
+			final:       types.StatusUP,
+		},
+		{
+			name:        "duplicate_reports",
+			transitions: []string{types.StatusConnecting, types.StatusUP, types.StatusUP, types.StatusDisconnected, types.StatusReconnecting, types.StatusDisconnected},
+			expected:    []bool{true, true, false, true, true, false},
+			final:       types.StatusReconnecting,
+		},
+		{
+			name:        "failed",
+			transitions: []string{types.StatusConnecting, types.StatusDisconnected, types.StatusFailed, types.StatusConnecting, types.StatusStopped},
+			expected:    []bool{true, true, true, false, true},
+			final:       types.StatusStopped,
+		},
+		{
+			name:        "stopped",
+			transitions: []string{types.StatusConnecting, types.StatusUP, types.StatusStopped, types.StatusDisconnected, types.StatusUP},
+			expected:    []bool{true, true, true, false, false},
+			final:       types.StatusStopped,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sm := newStateMachine()
+			for index, status := range test.transitions {
+				err := sm.Transition(status, fmt.Sprintf("step %d", index))
+				assert.Equal(t, test.expected[index], err == nil, "transition to %s, index:%d", status, index)
+			}
+			assert.Equal(t, test.final, sm.State().Status)
+			assert.True(t, sm.Is(test.final))
+		})
+	}
+}
+
+func TestStateMachineHistory(t *testing.T) {
+	sm := newStateMachine()
+	assert.NoError(t, sm.Transition(types.StatusConnecting, ""))
+	for index := 0; index < stateHistorySize; index++ {
+		assert.NoError(t, sm.Transition(types.StatusDisconnected, fmt.Sprintf("lost %d", index)))
+		assert.NoError(t, sm.Transition(types.StatusReconnecting, ""))
+		assert.NoError(t, sm.Transition(types.StatusConnecting, ""))
+	}
+
+	history := sm.History()
+	assert.Len(t, history, stateHistorySize)
+	last := history[len(history)-1]
+	assert.Equal(t, types.StatusReconnecting, last.From)
+	assert.Equal(t, types.StatusConnecting, last.To)
+	assert.Equal(t, fmt.Sprintf("lost %d", stateHistorySize-1), history[len(history)-3].Reason)
+}
+
+func TestStatusGate(t *testing.T) {
+	var generation atomic.Int64
+	reported := []string{}
+	statusFunc := func(state *types.State) { reported = append(reported, state.Status) }
+
+	// held until opened
+	gate := newStatusGate(&generation, statusFunc)
+	gate.Report(&types.State{Status: types.StatusUP})
+	assert.Empty(t, reported)
+	gate.Open()
+	gate.Report(&types.State{Status: types.StatusError})
+	assert.Equal(t, []string{types.StatusUP, types.StatusError}, reported)
+
+	// replaced instance reports are dropped
+	newGate := newStatusGate(&generation, statusFunc)
+	gate.Report(&types.State{Status: types.StatusError})
+	newGate.Open()
+	newGate.Report(&types.State{Status: types.StatusUP})
+	assert.Equal(t, []string{types.StatusUP, types.StatusError, types.StatusUP}, reported)
+}
@@ -0,0 +1,92 @@
+package adapter
+
+import (
+	"time"
+
+	"github.com/mycontroller-org/2mqtt/pkg/queue"
+	"github.com/mycontroller-org/2mqtt/pkg/types"
+)
+
+// Status of an adapter
+type Status struct {
+	Name    string      `json:"name"`
+	Running bool        `json:"running"`
+	Source  types.State `json:"source"`
+	MQTT    types.State `json:"mqtt"`
+
+	SourceReconnect ReconnectStatus `json:"source_reconnect"`
+	MQTTReconnect   ReconnectStatus `json:"mqtt_reconnect"`
+
+	SourceLastReceived *time.Time `json:"source_last_received,omitempty"` // reported when the watchdog is enabled
+
+	SourceHistory []Transition `json:"source_history,omitempty"` // recent state transitions, oldest first
+	MQTTHistory   []Transition `json:"mqtt_history,omitempty"`
+
+	Queues map[string]queue.Stats `json:"queues,omitempty"`
+}
+
+// ReconnectStatus of a device, next retry will be empty when there is no reconnect scheduled
+type ReconnectStatus struct {
+	Attempts  int        `json:"attempts"`
+	NextRetry *time.Time `json:"next_retry,omitempty"`
+}
+
+// Ready returns true, if both source and mqtt devices are up
+func (st Status) Ready() bool {
+	return st.Running && st.Source.Status == types.StatusUP && st.MQTT.Status == types.StatusUP
+}
+
+// GetStatus returns the status of an adapter
+func GetStatus(name string) Status {
+	service := servicesStore.Get(name)
+	if service == nil {
+		return Status{Name: name}
+	}
+	return service.Status()
+}
+
+// Readiness returns the status of the adapters and true if all of them are ready.
+// if the names are empty, all the enabled adapters will be verified
+func Readiness(names []string) (bool, []Status) {
+	if len(names) == 0 {
+		names = servicesStore.Enabled()
+	}
+
+	ready := true
+	statuses := make([]Status, 0, len(names))
+	for _, name := range names {
+		status := GetStatus(name)
+		if !status.Ready() {
+			ready = false
+		}
+		statuses = append(statuses, status)
+	}
+	return ready, statuses
+}
+
+// Status returns the current status of the service
+func (s *Service) Status() Status {
+	var lastReceived *time.Time
+	if s.watchdog != nil {
+		lastReceived = s.watchdog.LastReceived()
+	}
+	return Status{
+		Name:    s.adapterConfig.Name,
+		Running: true,
+		Source:  s.sourceState.State(),
+		MQTT:    s.mqttState.State(),
+
+		SourceReconnect: s.sourceBackoff.Status(),
+		MQTTReconnect:   s.mqttBackoff.Status(),
+
+		SourceLastReceived: lastReceived,
+
+		SourceHistory: s.sourceState.History(),
+		MQTTHistory:   s.mqttState.History(),
+
+		Queues: map[string]queue.Stats{
+			directionToMqtt:   s.mqttMessageQueue.Stats(),
+			directionToSource: s.sourceMessageQueue.Stats(),
+		},
+	}
+}
@@ -0,0 +1,29 @@
+package adapter
+
+import (
+	"github.com/mycontroller-org/2mqtt/pkg/types"
+	"github.com/mycontroller-org/server/v2/pkg/json"
+	"go.uber.org/zap"
+)
+
+// publishSourceStatus publishes the source device state as retained json on the status topic, if configured
+func (s *Service) publishSourceStatus() {
+	statusTopic := s.adapterConfig.MQTT.GetString(types.KeyMqttStatusTopic)
+	mqttDevice := s.getMqttDevice()
+	if statusTopic == "" || !s.isMqttUP() || mqttDevice == nil {
+		return
+	}
+
+	data, err := json.Marshal(s.sourceState.State())
+	if err != nil {
+		s.logger.Error("error on converting source status to json", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Error(err))
+		return
+	}
+
+	message := types.NewMessage(data)
+	message.Others.Set(types.KeyMqttAbsoluteTopic, statusTopic, nil)
+	message.Others.Set(types.KeyMqttRetain, true, nil)
+	if err = mqttDevice.Write(message); err != nil {
+		s.logger.Error("error on publishing source status", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("topic", statusTopic), zap.Error(err))
+	}
+}
@@ -2,42 +2,164 @@ package adapter
 
 import (
 	"context"
+	"reflect"
+	"sort"
 	"sync"
+	"time"
 
 	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
 	contextTY "github.com/mycontroller-org/2mqtt/pkg/types/context"
+	"github.com/mycontroller-org/server/v2/pkg/types/cmap"
+	"github.com/mycontroller-org/server/v2/pkg/utils"
 	"go.uber.org/zap"
 )
 
 type store struct {
-	services map[string]*Service
-	mutex    *sync.Mutex
+	ctx         context.Context
+	services    map[string]*Service
+	configs     map[string]config.AdapterConfig // configuration as supplied, providers may modify the service config
+	adapters    []config.AdapterConfig          // all the adapters from the config, includes disabled and the failed to start adapters
+	mutex       *sync.Mutex
+	actionMutex *sync.Mutex // serializes reload and management actions
 }
 
 var servicesStore = store{
-	services: make(map[string]*Service),
-	mutex:    &sync.Mutex{},
+	services:    make(map[string]*Service),
+	configs:     make(map[string]config.AdapterConfig),
+	adapters:    make([]config.AdapterConfig, 0),
+	mutex:       &sync.Mutex{},
+	actionMutex: &sync.Mutex{},
 }
 
 // Add a service
-func (s *store) Add(service *Service) {
+func (s *store) Add(service *Service, adapterCfg config.AdapterConfig) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	s.services[service.adapterConfig.Name] = service
+	s.services[adapterCfg.Name] = service
+	s.configs[adapterCfg.Name] = adapterCfg
 }
 
-// Remove all the services
-func (s *store) StopAll() {
+// Remove stops and removes a service.
+// the service is stopped after releasing the lock, stop waits for the queued messages
+func (s *store) Remove(name string) {
+	drainTimeout := s.drainTimeout()
+	s.mutex.Lock()
+	service, found := s.services[name]
+	delete(s.services, name)
+	delete(s.configs, name)
+	s.mutex.Unlock()
+
+	if found && service != nil {
+		service.Stop(drainTimeout)
+	}
+}
+
+// Configs returns the configurations of the running services
+func (s *store) Configs() map[string]config.AdapterConfig {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	configs := make(map[string]config.AdapterConfig)
+	for name, adapterCfg := range s.configs {
+		configs[name] = adapterCfg
+	}
+	return configs
+}
+
+// Get returns a service
+func (s *store) Get(name string) *Service {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.services[name]
+}
+
+// SetAdapters updates the adapters and the context used to start the services
+func (s *store) SetAdapters(ctx context.Context, adapters []config.AdapterConfig) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.ctx = ctx
+	s.adapters = append([]config.AdapterConfig{}, adapters...)
+}
+
+// Adapters returns all the adapters
+func (s *store) Adapters() []config.AdapterConfig {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]config.AdapterConfig{}, s.adapters...)
+}
+
+// Enabled returns the enabled adapter names
+func (s *store) Enabled() []string {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	for k := range servicesStore.services {
-		service := servicesStore.services[k]
-		if service != nil {
-			service.Stop()
+	enabled := make([]string, 0)
+	for _, adapterCfg := range s.adapters {
+		if adapterCfg.Enabled {
+			enabled = append(enabled, adapterCfg.Name)
 		}
 	}
+	return enabled
+}
+
+// UpdateEnabled updates the enabled flag of an adapter, returns false if the adapter not found
+func (s *store) UpdateEnabled(name string, enabled bool) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for index := range s.adapters {
+		if s.adapters[index].Name == name {
+			s.adapters[index].Enabled = enabled
+			return true
+		}
+	}
+	return false
+}
+
+// Context returns the context used to start the services
+func (s *store) Context() context.Context {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.ctx
+}
+
+// drainTimeout returns the drain timeout from the current configuration, updated on reload
+func (s *store) drainTimeout() time.Duration {
+	ctx := s.Context()
+	if ctx == nil {
+		return DefaultDrainTimeout
+	}
+	cfg, err := contextTY.ConfigFromContext(ctx)
+	if err != nil {
+		return DefaultDrainTimeout
+	}
+	return utils.ToDuration(cfg.Shutdown.DrainTimeout, DefaultDrainTimeout)
+}
+
+// StopAll stops and removes all the services
+func (s *store) StopAll() {
+	drainTimeout := s.drainTimeout()
+	s.mutex.Lock()
+	services := s.services
+	s.services = make(map[string]*Service)
+	s.configs = make(map[string]config.AdapterConfig)
+	s.mutex.Unlock()
+
+	// stops in parallel, each service drains the queued messages
+	wg := sync.WaitGroup{}
+	for _, service := range services {
+		if service == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(service *Service) {
+			defer wg.Done()
+			service.Stop(drainTimeout)
+		}(service)
+	}
+	wg.Wait()
 }
 
 // Start all the services
@@ -47,24 +169,109 @@ func Start(ctx context.Context, adapters []config.AdapterConfig) error {
 		return err
 	}
 
+	servicesStore.actionMutex.Lock()
+	defer servicesStore.actionMutex.Unlock()
+
+	servicesStore.SetAdapters(ctx, adapters)
 	for index := range adapters {
 		adapterCfg := adapters[index]
 		if !adapterCfg.Enabled {
 			continue
 		}
-		logger.Debug("starting an adapter", zap.String("name", adapterCfg.Name), zap.String("provider", adapterCfg.Provider))
-		service, err := NewService(ctx, &adapterCfg)
-		if err != nil {
-			logger.Error("error on starting a service", zap.Error(err), zap.String("adapterName", adapterCfg.Name))
+		_ = startService(ctx, logger, adapterCfg)
+	}
+
+	return nil
+}
+
+// Reload applies the changes on the adapters.
+// only the added, removed and modified adapters will be stopped or started, others will be untouched
+func Reload(ctx context.Context, adapters []config.AdapterConfig) error {
+	logger, err := contextTY.LoggerFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	servicesStore.actionMutex.Lock()
+	defer servicesStore.actionMutex.Unlock()
+
+	servicesStore.SetAdapters(ctx, adapters)
+	toStop, toStart := diffAdapters(servicesStore.Configs(), adapters)
+	logger.Info("reloading adapters", zap.Strings("stop", toStop), zap.Int("start", len(toStart)))
+
+	for _, name := range toStop {
+		logger.Debug("stopping an adapter", zap.String("name", name))
+		servicesStore.Remove(name)
+	}
+
+	for _, adapterCfg := range toStart {
+		_ = startService(ctx, logger, adapterCfg)
+	}
+
+	return nil
+}
+
+// diffAdapters returns the names of the adapters to be stopped and the adapters to be started.
+// a modified adapter will be on both lists
+func diffAdapters(current map[string]config.AdapterConfig, adapters []config.AdapterConfig) ([]string, []config.AdapterConfig) {
+	toStop := make([]string, 0)
+	toStart := make([]config.AdapterConfig, 0)
+
+	enabled := make(map[string]config.AdapterConfig)
+	for _, adapterCfg := range adapters {
+		if adapterCfg.Enabled {
+			enabled[adapterCfg.Name] = adapterCfg
+		}
+	}
+
+	for name, currentCfg := range current {
+		newCfg, found := enabled[name]
+		if !found || !reflect.DeepEqual(currentCfg, newCfg) {
+			toStop = append(toStop, name)
+		}
+	}
+	sort.Strings(toStop)
+
+	for _, adapterCfg := range adapters {
+		if !adapterCfg.Enabled {
 			continue
 		}
-		service.Start()
-		servicesStore.Add(service)
+		currentCfg, found := current[adapterCfg.Name]
+		if !found || !reflect.DeepEqual(currentCfg, adapterCfg) {
+			toStart = append(toStart, adapterCfg)
+		}
 	}
 
+	return toStop, toStart
+}
+
+func startService(ctx context.Context, logger *zap.Logger, adapterCfg config.AdapterConfig) error {
+	logger.Debug("starting an adapter", zap.String("name", adapterCfg.Name), zap.String("provider", adapterCfg.Provider))
+	serviceCfg := cloneAdapterConfig(adapterCfg)
+	service, err := NewService(ctx, &serviceCfg)
+	if err != nil {
+		logger.Error("error on starting a service", zap.Error(err), zap.String("adapterName", adapterCfg.Name))
+		return err
+	}
+	service.Start()
+	servicesStore.Add(service, adapterCfg)
 	return nil
 }
 
+// cloneAdapterConfig copies the device maps, providers update the source config
+func cloneAdapterConfig(adapterCfg config.AdapterConfig) config.AdapterConfig {
+	cloned := adapterCfg
+	cloned.Source = make(cmap.CustomMap)
+	for key, value := range adapterCfg.Source {
+		cloned.Source[key] = value
+	}
+	cloned.MQTT = make(cmap.CustomMap)
+	for key, value := range adapterCfg.MQTT {
+		cloned.MQTT[key] = value
+	}
+	return cloned
+}
+
 // Close stops all the services
 func Close() {
 	servicesStore.StopAll()
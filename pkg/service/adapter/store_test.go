@@ -0,0 +1,69 @@
+package adapter
+
+import (
+	"testing"
+
+	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
+	"github.com/mycontroller-org/server/v2/pkg/types/cmap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffAdapters(t *testing.T) {
+	serial := config.AdapterConfig{Name: "serial", Enabled: true, Provider: "raw", Source: cmap.CustomMap{"type": "serial", "port": "/dev/ttyUSB0"}}
+	ethernet := config.AdapterConfig{Name: "ethernet", Enabled: true, Provider: "raw", Source: cmap.CustomMap{"type": "ethernet"}}
+	modifiedSerial := serial
+	modifiedSerial.Source = cmap.CustomMap{"type": "serial", "port": "/dev/ttyUSB1"}
+	disabledEthernet := ethernet
+	disabledEthernet.Enabled = false
+	http := config.AdapterConfig{Name: "http", Enabled: true, Provider: "raw", Source: cmap.CustomMap{"type": "http"}}
+
+	tests := []struct {
+		testName        string
+		current         map[string]config.AdapterConfig
+		adapters        []config.AdapterConfig
+		expectedToStop  []string
+		expectedToStart []string
+	}{
+		{
+			testName:        "TestNoChange",
+			current:         map[string]config.AdapterConfig{"serial": serial, "ethernet": ethernet},
+			adapters:        []config.AdapterConfig{serial, ethernet},
+			expectedToStop:  []string{},
+			expectedToStart: []string{},
+		},
+		{
+			testName:        "TestModified",
+			current:         map[string]config.AdapterConfig{"serial": serial, "ethernet": ethernet},
+			adapters:        []config.AdapterConfig{modifiedSerial, ethernet},
+			expectedToStop:  []string{"serial"},
+			expectedToStart: []string{"serial"},
+		},
+		{
+			testName:        "TestAddedAndRemoved",
+			current:         map[string]config.AdapterConfig{"serial": serial, "ethernet": ethernet},
+			adapters:        []config.AdapterConfig{serial, http},
+			expectedToStop:  []string{"ethernet"},
+			expectedToStart: []string{"http"},
+		},
+		{
+			testName:        "TestDisabled",
+			current:         map[string]config.AdapterConfig{"serial": serial, "ethernet": ethernet},
+			adapters:        []config.AdapterConfig{serial, disabledEthernet},
+			expectedToStop:  []string{"ethernet"},
+			expectedToStart: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			toStop, toStart := diffAdapters(test.current, test.adapters)
+			assert.Equal(t, test.expectedToStop, toStop)
+
+			names := []string{}
+			for _, adapterCfg := range toStart {
+				names = append(names, adapterCfg.Name)
+			}
+			assert.Equal(t, test.expectedToStart, names)
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package adapter
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/mycontroller-org/2mqtt/pkg/service/metrics"
+	"github.com/mycontroller-org/2mqtt/pkg/types"
+	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
+	"github.com/mycontroller-org/server/v2/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// watchdog defaults
+const (
+	DefaultWatchdogInactivityTimeout = "5m"
+	DefaultWatchdogProbeTimeout      = "10s"
+
+	watchdogCheckInterval = time.Second
+)
+
+// watchdog actions
+const (
+	watchdogNone = iota
+	watchdogProbe
+	watchdogReconnect
+)
+
+// watchdog tracks the data received from the source device
+type watchdog struct {
+	inactivityTimeout time.Duration
+	probeTimeout      time.Duration
+	probe             []byte
+	lastReceived      atomic.Int64 // unix nano, zero if nothing received
+	probeSentAt       atomic.Int64 // unix nano, zero if no probe sent
+}
+
+func newWatchdog(cfg config.WatchdogConfig) *watchdog {
+	w := &watchdog{
+		inactivityTimeout: utils.ToDuration(cfg.InactivityTimeout, 0),
+		probeTimeout:      utils.ToDuration(cfg.ProbeTimeout, 0),
+	}
+	if w.inactivityTimeout <= 0 {
+		w.inactivityTimeout, _ = time.ParseDuration(DefaultWatchdogInactivityTimeout)
+	}
+	if w.probeTimeout <= 0 {
+		w.probeTimeout, _ = time.ParseDuration(DefaultWatchdogProbeTimeout)
+	}
+	if cfg.Probe != "" {
+		w.probe = []byte(cfg.Probe)
+	}
+	return w
+}
+
+// received updates the last received time
+func (w *watchdog) received(now time.Time) {
+	w.lastReceived.Store(now.UnixNano())
+}
+
+// LastReceived returns the last received time, nil if nothing received
+func (w *watchdog) LastReceived() *time.Time {
+	lastReceived := w.lastReceived.Load()
+	if lastReceived == 0 {
+		return nil
+	}
+	_time := time.Unix(0, lastReceived)
+	return &_time
+}
+
+// check returns the action to be taken, upSince is the time the source device moved to up state.
+// the probe is sent once on each inactive period, inactive period restarts on receiving data
+func (w *watchdog) check(now, upSince time.Time) int {
+	lastActivity := upSince
+	if lastReceived := w.lastReceived.Load(); lastReceived > lastActivity.UnixNano() {
+		lastActivity = time.Unix(0, lastReceived)
+	}
+	if now.Sub(lastActivity) < w.inactivityTimeout {
+		return watchdogNone
+	}
+	if len(w.probe) == 0 {
+		return watchdogReconnect
+	}
+	probeSentAt := w.probeSentAt.Load()
+	if probeSentAt < lastActivity.UnixNano() {
+		return watchdogProbe
+	}
+	if now.Sub(time.Unix(0, probeSentAt)) < w.probeTimeout {
+		return watchdogNone
+	}
+	return watchdogReconnect
+}
+
+// startWatchdog schedules the inactivity check on the source device
+func (s *Service) startWatchdog() {
+	if s.watchdog == nil {
+		return
+	}
+	err := s.scheduler.Schedule(s.watchdogID, watchdogCheckInterval.String(), s.checkWatchdog)
+	if err != nil {
+		s.logger.Error("error on configuring a schedule", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("id", s.watchdogID), zap.Error(err))
+	}
+}
+
+// checkWatchdog sends the probe or reconnects the source device, if there is no data received.
+// verified only when the source device is up
+func (s *Service) checkWatchdog() {
+	state := s.sourceState.State()
+	if state.Status != types.StatusUP {
+		return
+	}
+
+	switch s.watchdog.check(time.Now(), state.Since) {
+	case watchdogProbe:
+		s.watchdog.probeSentAt.Store(time.Now().UnixNano())
+		if s.getSourceDevice() == nil {
+			return
+		}
+		s.logger.Debug("no data received from the source device, sending probe", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider))
+		err := s.writeSourceDevice(&types.Message{Data: s.watchdog.probe, Others: make(map[string]interface{}), Timestamp: time.Now()})
+		if err != nil {
+			metrics.Inc(metrics.WriteErrors, s.adapterConfig.Name, deviceSource)
+			s.logger.Error("error on writing the probe to the source device", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.Error(err))
+		}
+
+	case watchdogReconnect:
+		reason := fmt.Sprintf("no data received within %s", s.watchdog.inactivityTimeout)
+		if len(s.watchdog.probe) > 0 {
+			reason = fmt.Sprintf("%s, no response to the probe within %s", reason, s.watchdog.probeTimeout)
+		}
+		if !s.transition(deviceSource, types.StatusDisconnected, reason) {
+			return
+		}
+		metrics.Inc(metrics.WatchdogTriggers, s.adapterConfig.Name)
+		s.logger.Warn("source device is inactive, reconnecting", zap.String("adapterName", s.adapterConfig.Name), zap.String("provider", s.adapterConfig.Provider), zap.String("reason", reason))
+		// reconnects immediately, the reconnect policy applies if the reconnect fails
+		if s.transition(deviceSource, types.StatusReconnecting, "watchdog") {
+			s.reconnectSourceDevice()
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package adapter
+
+import (
+	"testing"
+	"time"
+
+	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchdogCheck(t *testing.T) {
+	upSince := time.Now()
+	tests := []struct {
+		testName     string
+		config       config.WatchdogConfig
+		lastReceived time.Duration // after up, zero if nothing received
+		probeSent    time.Duration // after up, zero if no probe sent
+		now          time.Duration // after up
+		expected     int
+	}{
+		{
+			testName: "TestActive",
+			config:   config.WatchdogConfig{InactivityTimeout: "1m"},
+			now:      time.Second * 30,
+			expected: watchdogNone,
+		},
+		{
+			testName:     "TestReceivedRecently",
+			config:       config.WatchdogConfig{InactivityTimeout: "1m"},
+			lastReceived: time.Second * 50,
+			now:          time.Second * 70,
+			expected:     watchdogNone,
+		},
+		{
+			testName: "TestInactiveWithoutProbe",
+			config:   config.WatchdogConfig{InactivityTimeout: "1m"},
+			now:      time.Second * 61,
+			expected: watchdogReconnect,
+		},
+		{
+			testName: "TestInactiveSendProbe",
+			config:   config.WatchdogConfig{InactivityTimeout: "1m", Probe: "ping", ProbeTimeout: "5s"},
+			now:      time.Second * 61,
+			expected: watchdogProbe,
+		},
+		{
+			testName:  "TestWaitingProbeResponse",
+			config:    config.WatchdogConfig{InactivityTimeout: "1m", Probe: "ping", ProbeTimeout: "5s"},
+			probeSent: time.Second * 61,
+			now:       time.Second * 64,
+			expected:  watchdogNone,
+		},
+		{
+			testName:  "TestNoProbeResponse",
+			config:    config.WatchdogConfig{InactivityTimeout: "1m", Probe: "ping", ProbeTimeout: "5s"},
+			probeSent: time.Second * 61,
+			now:       time.Second * 67,
+			expected:  watchdogReconnect,
+		},
+		{
+			testName:     "TestProbeAnswered",
+			config:       config.WatchdogConfig{InactivityTimeout: "1m", Probe: "ping", ProbeTimeout: "5s"},
+			probeSent:    time.Second * 61,
+			lastReceived: time.Second * 62,
+			now:          time.Second * 67,
+			expected:     watchdogNone,
+		},
+		{
+			testName:     "TestProbeOnNextInactivePeriod",
+			config:       config.WatchdogConfig{InactivityTimeout: "1m", Probe: "ping", ProbeTimeout: "5s"},
+			probeSent:    time.Second * 61,
+			lastReceived: time.Second * 62,
+			now:          time.Second * 123,
+			expected:     watchdogProbe,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			w := newWatchdog(test.config)
+			if test.lastReceived > 0 {
+				w.received(upSince.Add(test.lastReceived))
+			}
+			if test.probeSent > 0 {
+				w.probeSentAt.Store(upSince.Add(test.probeSent).UnixNano())
+			}
+			assert.Equal(t, test.expected, w.check(upSince.Add(test.now), upSince))
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package httpserver
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	adapterSVC "github.com/mycontroller-org/2mqtt/pkg/service/adapter"
+	"github.com/mycontroller-org/server/v2/pkg/json"
+	"go.uber.org/zap"
+)
+
+// api endpoints
+const (
+	PathAPIAdapters = "/api/adapters"
+)
+
+// adapter actions
+const (
+	ActionStart   = "start"
+	ActionStop    = "stop"
+	ActionRestart = "restart"
+	ActionEnable  = "enable"
+	ActionDisable = "disable"
+	ActionSend    = "send"
+)
+
+// maximum size of a send request body
+const maxSendRequestSize = 1024 * 1024
+
+// ErrorResponse struct
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// ActionResponse struct
+type ActionResponse struct {
+	Action string            `json:"action"`
+	Status adapterSVC.Status `json:"status"`
+}
+
+// adapters handles the adapter management requests
+//
+//	GET  /api/adapters                 - lists all the adapters
+//	GET  /api/adapters/{name}          - returns an adapter
+//	POST /api/adapters/{name}/{action} - actions: start, stop, restart, enable, disable
+//	POST /api/adapters/{name}/send     - writes a message to the device, body: adapterSVC.SendRequest
+func (s *Server) adapters(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, PathAPIAdapters), "/")
+	parts := []string{}
+	if path != "" {
+		parts = strings.Split(path, "/")
+	}
+
+	switch {
+	case len(parts) == 0 && r.Method == http.MethodGet:
+		s.writeJSON(w, http.StatusOK, adapterSVC.List())
+
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		info, err := adapterSVC.Get(parts[0])
+		if err != nil {
+			s.writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+			return
+		}
+		s.writeJSON(w, http.StatusOK, info)
+
+	case len(parts) == 2 && r.Method == http.MethodPost && parts[1] == ActionSend:
+		s.adapterSend(w, r, parts[0])
+
+	case len(parts) == 2 && r.Method == http.MethodPost:
+		s.adapterAction(w, parts[0], parts[1])
+
+	default:
+		s.writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not found"})
+	}
+}
+
+func (s *Server) adapterAction(w http.ResponseWriter, name, action string) {
+	var err error
+	switch action {
+	case ActionStart:
+		err = adapterSVC.StartAdapter(name)
+	case ActionStop:
+		err = adapterSVC.StopAdapter(name)
+	case ActionRestart:
+		err = adapterSVC.RestartAdapter(name)
+	case ActionEnable:
+		err = adapterSVC.EnableAdapter(name)
+	case ActionDisable:
+		err = adapterSVC.DisableAdapter(name)
+	default:
+		s.writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "unsupported action: " + action})
+		return
+	}
+
+	if err != nil {
+		s.logger.Error("error on adapter action", zap.String("adapterName", name), zap.String("action", action), zap.Error(err))
+		s.writeJSON(w, errorStatusCode(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+	s.logger.Info("adapter action completed", zap.String("adapterName", name), zap.String("action", action))
+	s.writeJSON(w, http.StatusOK, ActionResponse{Action: action, Status: adapterSVC.GetStatus(name)})
+}
+
+func (s *Server) adapterSend(w http.ResponseWriter, r *http.Request, name string) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSendRequestSize))
+	if err != nil {
+		s.writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	request := adapterSVC.SendRequest{}
+	if err = json.Unmarshal(body, &request); err != nil {
+		s.writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	result, err := adapterSVC.Send(name, request)
+	if err != nil {
+		s.logger.Error("error on sending a message", zap.String("adapterName", name), zap.String("direction", request.Direction), zap.Error(err))
+		s.writeJSON(w, errorStatusCode(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+	s.writeJSON(w, http.StatusOK, result)
+}
+
+// errorStatusCode returns not found for an unknown adapter, bad request for the other errors
+func errorStatusCode(err error) int {
+	if errors.Is(err, adapterSVC.ErrAdapterNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
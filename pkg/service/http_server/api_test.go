@@ -0,0 +1,37 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestAdaptersNotFound(t *testing.T) {
+	s := &Server{logger: zap.NewNop()}
+	tests := []struct {
+		method string
+		path   string
+	}{
+		{method: http.MethodGet, path: "/api/adapters/unknown"},
+		{method: http.MethodPost, path: "/api/adapters/unknown/start"},
+		{method: http.MethodPost, path: "/api/adapters/unknown/stop"},
+		{method: http.MethodPost, path: "/api/adapters/unknown/restart"},
+		{method: http.MethodPost, path: "/api/adapters/unknown/enable"},
+		{method: http.MethodPost, path: "/api/adapters/unknown/disable"},
+		{method: http.MethodPost, path: "/api/adapters/unknown/send"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			request := httptest.NewRequest(test.method, test.path, strings.NewReader(`{"direction":"to_source","data":"hello"}`))
+			recorder := httptest.NewRecorder()
+			s.adapters(recorder, request)
+			assert.Equal(t, http.StatusNotFound, recorder.Code)
+			assert.Contains(t, recorder.Body.String(), "adapter not found")
+		})
+	}
+}
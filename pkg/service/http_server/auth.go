@@ -0,0 +1,45 @@
+package httpserver
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
+)
+
+const (
+	headerAuthorization = "Authorization"
+	bearerPrefix        = "Bearer "
+)
+
+// middlewareAuthentication verifies bearer token or basic authentication, whichever configured
+func middlewareAuthentication(cfg config.APIConfig, next http.Handler) http.Handler {
+	if cfg.Token == "" && cfg.Username == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get(headerAuthorization)
+
+		if cfg.Token != "" && strings.HasPrefix(authorization, bearerPrefix) {
+			if secureCompare(strings.TrimPrefix(authorization, bearerPrefix), cfg.Token) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		if cfg.Username != "" {
+			username, password, ok := r.BasicAuth()
+			if ok && secureCompare(username, cfg.Username) && secureCompare(password, cfg.Password) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Basic realm="Enter username and password"`)
+		}
+		http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+	})
+}
+
+func secureCompare(given, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(given), []byte(expected)) == 1
+}
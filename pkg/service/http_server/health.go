@@ -0,0 +1,70 @@
+package httpserver
+
+import (
+	"net/http"
+
+	adapterSVC "github.com/mycontroller-org/2mqtt/pkg/service/adapter"
+	"github.com/mycontroller-org/server/v2/pkg/json"
+	"go.uber.org/zap"
+)
+
+// health endpoints
+const (
+	PathLiveness  = "/healthz"
+	PathReadiness = "/readyz"
+)
+
+// LivenessResponse struct
+type LivenessResponse struct {
+	Alive   bool   `json:"alive"`
+	Message string `json:"message,omitempty"`
+}
+
+// ReadinessResponse struct
+type ReadinessResponse struct {
+	Ready    bool                `json:"ready"`
+	Adapters []adapterSVC.Status `json:"adapters"`
+}
+
+// liveness reports the process is alive and the scheduler is running
+func (s *Server) liveness(w http.ResponseWriter, r *http.Request) {
+	response := LivenessResponse{Alive: true}
+	if s.livenessFunc != nil {
+		if err := s.livenessFunc(); err != nil {
+			response.Alive = false
+			response.Message = err.Error()
+		}
+	}
+
+	statusCode := http.StatusOK
+	if !response.Alive {
+		statusCode = http.StatusServiceUnavailable
+	}
+	s.writeJSON(w, statusCode, response)
+}
+
+// readiness reports the source and mqtt devices of the adapters are up
+func (s *Server) readiness(w http.ResponseWriter, r *http.Request) {
+	ready, statuses := adapterSVC.Readiness(s.config.Readiness.Adapters)
+	response := ReadinessResponse{Ready: ready, Adapters: statuses}
+
+	statusCode := http.StatusOK
+	if !ready {
+		statusCode = http.StatusServiceUnavailable
+	}
+	s.writeJSON(w, statusCode, response)
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		s.logger.Error("error on converting response to json", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if _, err = w.Write(bytes); err != nil {
+		s.logger.Error("error on writing response", zap.Error(err))
+	}
+}
@@ -0,0 +1,99 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/mycontroller-org/2mqtt/pkg/service/metrics"
+	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
+	contextTY "github.com/mycontroller-org/2mqtt/pkg/types/context"
+	"go.uber.org/zap"
+)
+
+// default settings
+const (
+	DefaultListenAddress = "0.0.0.0:8080"
+
+	defaultReadTimeout     = time.Second * 60
+	defaultShutdownTimeout = time.Second * 5
+)
+
+// Server serves the metrics, health and admin api endpoints
+type Server struct {
+	logger       *zap.Logger
+	config       config.HTTPServerConfig
+	server       *http.Server
+	listener     net.Listener
+	livenessFunc func() error
+	shutdownCH   chan struct{} // closed on shutdown, terminates the streaming requests
+}
+
+// New returns a http server, livenessFunc used on the liveness endpoint
+func New(ctx context.Context, cfg config.HTTPServerConfig, livenessFunc func() error) (*Server, error) {
+	logger, err := contextTY.LoggerFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.ListenAddress == "" {
+		cfg.ListenAddress = DefaultListenAddress
+	}
+
+	s := &Server{
+		logger:       logger.Named("http_server"),
+		config:       cfg,
+		livenessFunc: livenessFunc,
+		shutdownCH:   make(chan struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc(PathLiveness, s.liveness)
+	mux.HandleFunc(PathReadiness, s.readiness)
+
+	// admin api
+	if cfg.API.Enabled {
+		if cfg.API.Token == "" && cfg.API.Username == "" {
+			s.logger.Warn("admin api is not protected, configure token or username and password")
+		}
+		apiHandler := middlewareAuthentication(cfg.API, http.HandlerFunc(s.adapters))
+		mux.Handle(PathAPIAdapters, apiHandler)
+		mux.Handle(PathAPIAdapters+"/", apiHandler)
+		mux.Handle(PathAPITraffic, middlewareAuthentication(cfg.API, http.HandlerFunc(s.traffic)))
+	}
+
+	s.server = &http.Server{
+		ReadTimeout: defaultReadTimeout,
+		Handler:     mux,
+	}
+	s.server.RegisterOnShutdown(func() { close(s.shutdownCH) })
+	return s, nil
+}
+
+// Start opens the listening address and serves the requests
+func (s *Server) Start() error {
+	s.logger.Info("opening the listening address", zap.String("listenAddress", s.config.ListenAddress))
+	listener, err := net.Listen("tcp", s.config.ListenAddress)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+
+	go func() {
+		err := s.server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("error serve", zap.String("listenAddress", s.config.ListenAddress), zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// Close stops the http server
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
@@ -0,0 +1,65 @@
+package httpserver
+
+import (
+	"net/http"
+
+	"github.com/mycontroller-org/2mqtt/pkg/traffic"
+	"github.com/mycontroller-org/server/v2/pkg/json"
+	"go.uber.org/zap"
+)
+
+// PathAPITraffic streams the live traffic
+const PathAPITraffic = "/api/traffic"
+
+// traffic streams the records as json lines, until the client disconnects or the server shutdown.
+// all the query parameters are optional
+//
+//	GET /api/traffic?adapter={name}&direction={to_mqtt|to_source}&stage={raw|formatted}&topic={pattern}&payload={regex}
+func (s *Server) traffic(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "streaming not supported"})
+		return
+	}
+
+	query := r.URL.Query()
+	filter, err := traffic.NewFilter(query.Get("adapter"), query.Get("direction"), query.Get("stage"), query.Get("topic"), query.Get("payload"))
+	if err != nil {
+		s.writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	subscription := traffic.Subscribe(filter)
+	defer subscription.Close()
+	s.logger.Info("traffic monitor connected", zap.String("remoteAddress", r.RemoteAddr), zap.String("query", r.URL.RawQuery))
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			s.logger.Info("traffic monitor disconnected", zap.String("remoteAddress", r.RemoteAddr), zap.Int64("dropped", subscription.Dropped()))
+			return
+
+		case <-s.shutdownCH:
+			return
+
+		case record := <-subscription.Records():
+			data, err := json.Marshal(record)
+			if err != nil {
+				s.logger.Error("error on converting traffic record to json", zap.Error(err))
+				continue
+			}
+			if _, err = w.Write(append(data, '\n')); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
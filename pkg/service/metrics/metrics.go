@@ -0,0 +1,187 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// metric names
+const (
+	MessagesReceived  = "twomqtt_messages_received_total"
+	MessagesSent      = "twomqtt_messages_sent_total"
+	FormatterErrors   = "twomqtt_formatter_errors_total"
+	WriteErrors       = "twomqtt_write_errors_total"
+	MessagesDropped   = "twomqtt_messages_dropped_total"
+	MessagesFiltered  = "twomqtt_messages_filtered_total"
+	MessagesDryRun    = "twomqtt_messages_dry_run_total"
+	ReconnectAttempts = "twomqtt_reconnect_attempts_total"
+	WatchdogTriggers  = "twomqtt_watchdog_triggers_total"
+	QueueDepth        = "twomqtt_queue_depth"
+	DeviceState       = "twomqtt_device_state"
+)
+
+// label names
+const (
+	LabelAdapter   = "adapter"
+	LabelDevice    = "device"
+	LabelDirection = "direction"
+	LabelReason    = "reason"
+	LabelQueue     = "queue"
+	LabelStatus    = "status"
+)
+
+// metric types
+const (
+	typeCounter = "counter"
+	typeGauge   = "gauge"
+)
+
+type description struct {
+	help       string
+	metricType string
+	labels     []string
+}
+
+var descriptions = map[string]description{
+	MessagesReceived:  {help: "Number of messages received from a device", metricType: typeCounter, labels: []string{LabelAdapter, LabelDevice}},
+	MessagesSent:      {help: "Number of messages written to a device", metricType: typeCounter, labels: []string{LabelAdapter, LabelDevice}},
+	FormatterErrors:   {help: "Number of errors on formatting a message", metricType: typeCounter, labels: []string{LabelAdapter, LabelDirection}},
+	WriteErrors:       {help: "Number of errors on writing a message to a device", metricType: typeCounter, labels: []string{LabelAdapter, LabelDevice}},
+	MessagesDropped:   {help: "Number of messages dropped", metricType: typeCounter, labels: []string{LabelAdapter, LabelDevice, LabelReason}},
+	MessagesFiltered:  {help: "Number of messages filtered", metricType: typeCounter, labels: []string{LabelAdapter, LabelDirection, LabelReason}},
+	MessagesDryRun:    {help: "Number of messages not written to a device on dry run", metricType: typeCounter, labels: []string{LabelAdapter, LabelDirection}},
+	ReconnectAttempts: {help: "Number of reconnect attempts to a device", metricType: typeCounter, labels: []string{LabelAdapter, LabelDevice}},
+	WatchdogTriggers:  {help: "Number of source reconnects triggered by the inactivity watchdog", metricType: typeCounter, labels: []string{LabelAdapter}},
+	QueueDepth:        {help: "Number of messages waiting on a queue", metricType: typeGauge, labels: []string{LabelAdapter, LabelQueue}},
+	DeviceState:       {help: "Current state of a device, 1 on the current status", metricType: typeGauge, labels: []string{LabelAdapter, LabelDevice, LabelStatus}},
+}
+
+// Sample of a gauge, reported by a collector on scrape
+type Sample struct {
+	Name        string
+	LabelValues []string
+	Value       float64
+}
+
+// CollectorFunc returns the current gauge values
+type CollectorFunc func() []Sample
+
+type registry struct {
+	mutex      sync.RWMutex
+	counters   map[string]map[string]float64 // metric name => label values => value
+	collectors map[string]CollectorFunc
+}
+
+var store = registry{
+	counters:   make(map[string]map[string]float64),
+	collectors: make(map[string]CollectorFunc),
+}
+
+// Inc increments a counter by one
+func Inc(name string, labelValues ...string) {
+	Add(name, 1, labelValues...)
+}
+
+// Add increments a counter by the given value
+func Add(name string, value float64, labelValues ...string) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	values, found := store.counters[name]
+	if !found {
+		values = make(map[string]float64)
+		store.counters[name] = values
+	}
+	values[strings.Join(labelValues, "\x00")] += value
+}
+
+// RegisterCollector adds or replaces a collector
+func RegisterCollector(id string, collector CollectorFunc) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.collectors[id] = collector
+}
+
+// UnregisterCollector removes a collector
+func UnregisterCollector(id string) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	delete(store.collectors, id)
+}
+
+// Handler serves the metrics in prometheus text format
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Write(w)
+	})
+}
+
+// Write writes all the metrics in prometheus text format
+func Write(w io.Writer) {
+	samples := make(map[string][]Sample)
+
+	store.mutex.RLock()
+	for name, values := range store.counters {
+		for key, value := range values {
+			samples[name] = append(samples[name], Sample{Name: name, LabelValues: strings.Split(key, "\x00"), Value: value})
+		}
+	}
+	collectors := make([]CollectorFunc, 0, len(store.collectors))
+	for _, collector := range store.collectors {
+		collectors = append(collectors, collector)
+	}
+	store.mutex.RUnlock()
+
+	for _, collector := range collectors {
+		for _, sample := range collector() {
+			samples[sample.Name] = append(samples[sample.Name], sample)
+		}
+	}
+
+	names := make([]string, 0, len(samples))
+	for name := range samples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		desc, found := descriptions[name]
+		if !found {
+			continue
+		}
+		fmt.Fprintf(w, "# HELP %s %s\n", name, desc.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, desc.metricType)
+
+		lines := make([]string, 0, len(samples[name]))
+		for _, sample := range samples[name] {
+			lines = append(lines, fmt.Sprintf("%s%s %v", name, formatLabels(desc.labels, sample.LabelValues), sample.Value))
+		}
+		sort.Strings(lines)
+		for _, line := range lines {
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(names))
+	for index, name := range names {
+		value := ""
+		if index < len(values) {
+			value = values[index]
+		}
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, labelValueEscaper.Replace(value)))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// labelValueEscaper escapes the label value as defined on the prometheus text format, other characters are kept as is
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrite(t *testing.T) {
+	Inc(MessagesReceived, "adapter1", "source")
+	Add(MessagesReceived, 2, "adapter1", "source")
+	Inc(MessagesReceived, "адаптер_ü", "mqtt")
+	Inc(WriteErrors, `quote"back\slash`+"\nnewline", "source")
+	Inc("unknown_metric", "adapter1")
+	RegisterCollector("test", func() []Sample {
+		return []Sample{{Name: QueueDepth, LabelValues: []string{"adapter1", "mqtt"}, Value: 5}}
+	})
+	defer UnregisterCollector("test")
+
+	buf := &bytes.Buffer{}
+	Write(buf)
+	output := buf.String()
+
+	expected := []string{
+		"# HELP twomqtt_messages_received_total Number of messages received from a device\n" +
+			"# TYPE twomqtt_messages_received_total counter\n" +
+			`twomqtt_messages_received_total{adapter="adapter1",device="source"} 3` + "\n" +
+			`twomqtt_messages_received_total{adapter="адаптер_ü",device="mqtt"} 1` + "\n",
+		"# TYPE twomqtt_write_errors_total counter\n" +
+			`twomqtt_write_errors_total{adapter="quote\"back\\slash\nnewline",device="source"} 1` + "\n",
+		"# HELP twomqtt_queue_depth Number of messages waiting on a queue\n" +
+			"# TYPE twomqtt_queue_depth gauge\n" +
+			`twomqtt_queue_depth{adapter="adapter1",queue="mqtt"} 5` + "\n",
+	}
+	for _, text := range expected {
+		assert.Contains(t, output, text)
+	}
+	assert.NotContains(t, output, "unknown_metric")
+}
@@ -0,0 +1,63 @@
+package traffic
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mycontroller-org/2mqtt/pkg/capture"
+)
+
+// data render modes
+const (
+	RenderAuto = "auto" // text for the printable data, otherwise hex
+	RenderText = "text" // quoted text, non printable characters escaped
+	RenderHex  = "hex"  // hex dump with ascii column
+)
+
+// timestamp layout on the rendered record
+const timeLayout = "15:04:05.000"
+
+// Render returns the record as human readable text.
+// first line holds the timestamp, adapter, direction, stage and others, followed by the data
+func Render(record capture.Record, mode string) string {
+	builder := strings.Builder{}
+	fmt.Fprintf(&builder, "%s %s %s %s", record.Timestamp.Format(timeLayout), record.Adapter, record.Direction, record.Stage)
+
+	keys := make([]string, 0, len(record.Others))
+	for key := range record.Others {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&builder, " %s=%v", key, record.Others[key])
+	}
+	fmt.Fprintf(&builder, " size=%d\n", len(record.Data))
+
+	if mode == RenderHex || (mode != RenderText && !isPrintable(record.Data)) {
+		for _, line := range strings.SplitAfter(strings.TrimSuffix(hex.Dump(record.Data), "\n"), "\n") {
+			builder.WriteString("  " + line)
+		}
+		builder.WriteString("\n")
+	} else {
+		builder.WriteString("  " + strconv.QuoteToASCII(string(record.Data)) + "\n")
+	}
+	return builder.String()
+}
+
+// returns true, if the data is a valid utf8 text without control characters, except the whitespaces
+func isPrintable(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	for _, r := range string(data) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,145 @@
+package traffic
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mycontroller-org/2mqtt/pkg/capture"
+	"github.com/mycontroller-org/2mqtt/pkg/filter"
+	"github.com/mycontroller-org/2mqtt/pkg/types"
+)
+
+// SubscriberBufferSize is the number of records kept for a slow subscriber, further records will be dropped
+const SubscriberBufferSize = 1000
+
+// Filter selects the records for a subscriber, empty fields match all
+type Filter struct {
+	Adapter   string
+	Direction string
+	Stage     string
+	Topic     string         // mqtt wildcard pattern (+, #)
+	Payload   *regexp.Regexp // payload regular expression
+}
+
+// NewFilter returns a filter, payload is a regular expression
+func NewFilter(adapter, direction, stage, topic, payload string) (*Filter, error) {
+	f := &Filter{Adapter: adapter, Direction: direction, Stage: stage, Topic: topic}
+	if payload != "" {
+		payloadRegex, err := regexp.Compile(payload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid payload regex [%s]: %w", payload, err)
+		}
+		f.Payload = payloadRegex
+	}
+	return f, nil
+}
+
+// Match returns true, if the record matches all the conditions
+func (f *Filter) Match(record capture.Record) bool {
+	if f == nil {
+		return true
+	}
+	if (f.Adapter != "" && f.Adapter != record.Adapter) ||
+		(f.Direction != "" && f.Direction != record.Direction) ||
+		(f.Stage != "" && f.Stage != record.Stage) {
+		return false
+	}
+	if f.Topic != "" && !filter.MatchTopic(f.Topic, Topic(record)) {
+		return false
+	}
+	return f.Payload == nil || f.Payload.Match(record.Data)
+}
+
+// Topic returns the mqtt topic of the record, empty if not available
+func Topic(record capture.Record) string {
+	for _, key := range []string{types.KeyMqttAbsoluteTopic, types.KeyMqttTopic} {
+		if topic, ok := record.Others[key].(string); ok && topic != "" {
+			return topic
+		}
+	}
+	return ""
+}
+
+// Subscription receives the matching records
+type Subscription struct {
+	filter  *Filter
+	records chan capture.Record
+	dropped atomic.Int64
+	once    sync.Once
+}
+
+// Records returns the records channel, closed on unsubscribe
+func (s *Subscription) Records() <-chan capture.Record {
+	return s.records
+}
+
+// Dropped returns the number of records dropped, as the subscriber was slow
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Close unsubscribes
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		hub.mutex.Lock()
+		delete(hub.subscriptions, s)
+		hub.count.Store(int64(len(hub.subscriptions)))
+		hub.mutex.Unlock()
+		close(s.records)
+	})
+}
+
+// hub distributes the records to the subscribers
+var hub = struct {
+	mutex         sync.RWMutex
+	subscriptions map[*Subscription]struct{}
+	count         atomic.Int64
+}{
+	subscriptions: make(map[*Subscription]struct{}),
+}
+
+// Subscribe returns a subscription, should be closed after use
+func Subscribe(f *Filter) *Subscription {
+	subscription := &Subscription{
+		filter:  f,
+		records: make(chan capture.Record, SubscriberBufferSize),
+	}
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	hub.subscriptions[subscription] = struct{}{}
+	hub.count.Store(int64(len(hub.subscriptions)))
+	return subscription
+}
+
+// HasSubscribers returns true, if there is a subscriber. used to skip the record creation
+func HasSubscribers() bool {
+	return hub.count.Load() > 0
+}
+
+// Publish sends the record to the matching subscribers, never blocks
+func Publish(record capture.Record) {
+	if !HasSubscribers() {
+		return
+	}
+	// others will be modified after publish, keep a copy
+	others := make(map[string]interface{}, len(record.Others))
+	for key, value := range record.Others {
+		others[key] = value
+	}
+	record.Others = others
+
+	hub.mutex.RLock()
+	defer hub.mutex.RUnlock()
+	for subscription := range hub.subscriptions {
+		if !subscription.filter.Match(record) {
+			continue
+		}
+		select {
+		case subscription.records <- record:
+		default:
+			subscription.dropped.Add(1)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package traffic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mycontroller-org/2mqtt/pkg/capture"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribe(t *testing.T) {
+	assert.False(t, HasSubscribers())
+
+	all := Subscribe(nil)
+	defer all.Close()
+	filter, err := NewFilter("adapter1", "to_mqtt", "", "sensors/+/temperature", "^2[0-9]")
+	assert.NoError(t, err)
+	filtered := Subscribe(filter)
+	assert.True(t, HasSubscribers())
+
+	records := []capture.Record{
+		{Adapter: "adapter1", Direction: "to_mqtt", Data: []byte("21.5"), Others: map[string]interface{}{"mqtt_topic": "sensors/1/temperature"}},
+		{Adapter: "adapter1", Direction: "to_mqtt", Data: []byte("31.5"), Others: map[string]interface{}{"mqtt_topic": "sensors/1/temperature"}},
+		{Adapter: "adapter1", Direction: "to_mqtt", Data: []byte("21.5"), Others: map[string]interface{}{"mqtt_topic": "sensors/1/humidity"}},
+		{Adapter: "adapter1", Direction: "to_source", Data: []byte("21.5"), Others: map[string]interface{}{"mqtt_topic": "sensors/1/temperature"}},
+		{Adapter: "adapter2", Direction: "to_mqtt", Data: []byte("21.5"), Others: map[string]interface{}{"mqtt_topic": "sensors/1/temperature"}},
+	}
+	for _, record := range records {
+		Publish(record)
+	}
+
+	assert.Len(t, all.Records(), len(records))
+	assert.Len(t, filtered.Records(), 1)
+	assert.Equal(t, records[0], <-filtered.Records())
+
+	filtered.Close()
+	_, open := <-filtered.Records()
+	assert.False(t, open)
+
+	_, err = NewFilter("", "", "", "", "[")
+	assert.Error(t, err)
+}
+
+func TestRender(t *testing.T) {
+	timestamp := time.Date(2024, 3, 30, 14, 31, 53, 806000000, time.UTC)
+	tests := []struct {
+		name     string
+		record   capture.Record
+		mode     string
+		expected string
+	}{
+		{
+			name:     "text",
+			record:   capture.Record{Timestamp: timestamp, Adapter: "adapter1", Direction: "to_mqtt", Stage: "raw", Data: []byte("1;255;3;0;2;\n")},
+			mode:     RenderAuto,
+			expected: "14:31:53.806 adapter1 to_mqtt raw size=13\n  \"1;255;3;0;2;\\n\"\n",
+		},
+		{
+			name:     "binary",
+			record:   capture.Record{Timestamp: timestamp, Adapter: "adapter1", Direction: "to_source", Stage: "formatted", Data: []byte{0x01, 0x02, 'A'}, Others: map[string]interface{}{"mqtt_topic": "in"}},
+			mode:     RenderAuto,
+			expected: "14:31:53.806 adapter1 to_source formatted mqtt_topic=in size=3\n  00000000  01 02 41                                          |..A|\n",
+		},
+		{
+			name:     "forced_text",
+			record:   capture.Record{Timestamp: timestamp, Adapter: "adapter1", Direction: "to_source", Stage: "formatted", Data: []byte{0x01, 'A'}},
+			mode:     RenderText,
+			expected: "14:31:53.806 adapter1 to_source formatted size=2\n  \"\\x01A\"\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, Render(test.record, test.mode))
+		})
+	}
+}
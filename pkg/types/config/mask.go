@@ -0,0 +1,112 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/mycontroller-org/server/v2/pkg/types/cmap"
+)
+
+// MaskedValue replaces the secrets
+const MaskedValue = "********"
+
+// secrets shorter than this length are not masked on the values, avoids masking the common short values
+const minSecretLength = 4
+
+// keys contain any of these words treated as secret
+var secretKeyWords = []string{"password", "token", "secret"}
+
+// resolved secret values, masked on the logs
+var (
+	secrets      = make(map[string]struct{})
+	secretsMutex sync.RWMutex
+)
+
+// RegisterSecret adds the value to be masked on the logs
+func RegisterSecret(value string) {
+	if len(value) < minSecretLength {
+		return
+	}
+	secretsMutex.Lock()
+	defer secretsMutex.Unlock()
+	secrets[value] = struct{}{}
+}
+
+// MaskString replaces the registered secret values
+func MaskString(value string) string {
+	secretsMutex.RLock()
+	defer secretsMutex.RUnlock()
+	for secret := range secrets {
+		value = strings.ReplaceAll(value, secret, MaskedValue)
+	}
+	return value
+}
+
+// Mask returns a copy of the config as generic map, secret keys and the registered secret values are masked.
+// used to log the device configs
+func Mask(config interface{}) interface{} {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil
+	}
+	var generic interface{}
+	if err = json.Unmarshal(data, &generic); err != nil {
+		return nil
+	}
+	return maskValue(generic)
+}
+
+// IsSecretKey returns true, if the key holds a secret
+func IsSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, word := range secretKeyWords {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaskMap returns a copy of the map, secret values are masked
+func MaskMap(data cmap.CustomMap) cmap.CustomMap {
+	if data == nil {
+		return nil
+	}
+	masked := make(cmap.CustomMap)
+	for key, value := range data {
+		if IsSecretKey(key) && value != nil && value != "" {
+			masked[key] = MaskedValue
+			continue
+		}
+		masked[key] = maskValue(value)
+	}
+	return masked
+}
+
+func maskValue(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case cmap.CustomMap:
+		return MaskMap(typedValue)
+	case map[string]interface{}:
+		return MaskMap(typedValue)
+	case []interface{}:
+		items := make([]interface{}, 0, len(typedValue))
+		for _, item := range typedValue {
+			items = append(items, maskValue(item))
+		}
+		return items
+	case string:
+		return MaskString(typedValue)
+	default:
+		return value
+	}
+}
+
+// Masked returns a copy of the adapter config, secret values are masked
+func (ac AdapterConfig) Masked() AdapterConfig {
+	masked := ac
+	masked.Source = MaskMap(ac.Source)
+	masked.MQTT = MaskMap(ac.MQTT)
+	return masked
+}
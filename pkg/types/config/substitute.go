@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// supported references:
+// ${ENV_VAR}, ${ENV_VAR:-default} and ${file:/run/secrets/x}
+// "$${" escapes the reference
+var referenceRegex = regexp.MustCompile(`\$?\$\{(file:([^}]+)|([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?)\}`)
+
+// values of these keys are used as is, including the nested values.
+// scripts use javascript template literals and regex replacement uses "${name}" groups
+var verbatimKeys = map[string]bool{
+	"formatter_script": true,
+	"script":           true,
+	"match_script":     true,
+	"replacement":      true,
+}
+
+// Substitute replaces the environment variable and the secret file references on the yaml scalar values.
+// values resolved from the secret files and from the secret keys are registered to mask on the logs
+func Substitute(node *yaml.Node) error {
+	return substitute(node, "")
+}
+
+func substitute(node *yaml.Node, key string) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := substitute(child, key); err != nil {
+				return err
+			}
+		}
+
+	case yaml.MappingNode:
+		for index := 0; index+1 < len(node.Content); index += 2 {
+			if verbatimKeys[node.Content[index].Value] {
+				continue
+			}
+			if err := substitute(node.Content[index+1], node.Content[index].Value); err != nil {
+				return err
+			}
+		}
+
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "${") {
+			return nil
+		}
+		value, err := resolve(node.Value, key)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		node.Value = value
+		// plain values are resolved again, to support numbers and booleans
+		if node.Style == 0 {
+			node.Tag = ""
+		}
+	}
+	return nil
+}
+
+func resolve(value, key string) (string, error) {
+	var resolveErr error
+	resolved := referenceRegex.ReplaceAllStringFunc(value, func(reference string) string {
+		if resolveErr != nil {
+			return reference
+		}
+		if strings.HasPrefix(reference, "$$") {
+			return reference[1:]
+		}
+
+		groups := referenceRegex.FindStringSubmatch(reference)
+		filePath, envName, hasDefault, defaultValue := groups[2], groups[3], groups[4] != "", groups[5]
+
+		// secret file
+		if filePath != "" {
+			data, err := os.ReadFile(filePath)
+			if err != nil {
+				resolveErr = fmt.Errorf("error on reading secret file [%s]: %w", filePath, err)
+				return reference
+			}
+			secret := strings.TrimRight(string(data), "\r\n")
+			RegisterSecret(secret)
+			return secret
+		}
+
+		// environment variable
+		envValue, found := os.LookupEnv(envName)
+		if !found {
+			if !hasDefault {
+				resolveErr = fmt.Errorf("environment variable [%s] is not set", envName)
+				return reference
+			}
+			envValue = defaultValue
+		}
+		if IsSecretKey(key) || IsSecretKey(envName) {
+			RegisterSecret(envValue)
+		}
+		return envValue
+	})
+	return resolved, resolveErr
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestSubstitute(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "broker_password")
+	assert.NoError(t, os.WriteFile(secretFile, []byte("my-file-secret\n"), 0600))
+	t.Setenv("TEST_BROKER", "tcp://127.0.0.1:1883")
+	t.Setenv("TEST_QOS", "1")
+	t.Setenv("TEST_USERNAME", "admin")
+
+	tests := []struct {
+		name     string
+		input    string
+		expected map[string]interface{}
+		err      string
+	}{
+		{
+			name:     "env",
+			input:    "broker: ${TEST_BROKER}\nqos: ${TEST_QOS}\nquoted: \"${TEST_QOS}\"",
+			expected: map[string]interface{}{"broker": "tcp://127.0.0.1:1883", "qos": 1, "quoted": "1"},
+		},
+		{
+			name:     "default",
+			input:    "username: ${TEST_USERNAME:-guest}\nclient_id: ${TEST_NOT_SET:-client_1}\nempty: x${TEST_NOT_SET:-}",
+			expected: map[string]interface{}{"username": "admin", "client_id": "client_1", "empty": "x"},
+		},
+		{
+			name:     "file",
+			input:    "password: ${file:" + secretFile + "}",
+			expected: map[string]interface{}{"password": "my-file-secret"},
+		},
+		{
+			name:     "escaped",
+			input:    "topic: $${TEST_BROKER}/in",
+			expected: map[string]interface{}{"topic": "${TEST_BROKER}/in"},
+		},
+		{
+			name: "scripts_verbatim",
+			input: "formatter_script:\n  to_mqtt: result = `${raw_data}_${TEST_QOS}`\n" +
+				"script: result = `${raw_data}`\nmatch_script: result = `${request}` == raw_data\nreplacement: ${node}-${TEST_QOS}",
+			expected: map[string]interface{}{
+				"formatter_script": map[string]interface{}{"to_mqtt": "result = `${raw_data}_${TEST_QOS}`"},
+				"script":           "result = `${raw_data}`",
+				"match_script":     "result = `${request}` == raw_data",
+				"replacement":      "${node}-${TEST_QOS}",
+			},
+		},
+		{
+			name:  "missing_env",
+			input: "mqtt:\n  password: ${TEST_NOT_SET}",
+			err:   "line 2: environment variable [TEST_NOT_SET] is not set",
+		},
+		{
+			name:  "missing_file",
+			input: "password: ${file:/not/found}",
+			err:   "line 1: error on reading secret file [/not/found]",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			document := &yaml.Node{}
+			assert.NoError(t, yaml.Unmarshal([]byte(test.input), document))
+			err := Substitute(document)
+			if test.err != "" {
+				assert.ErrorContains(t, err, test.err)
+				return
+			}
+			assert.NoError(t, err)
+			actual := map[string]interface{}{}
+			assert.NoError(t, document.Decode(&actual))
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+
+	// resolved secrets are masked
+	assert.Equal(t, "password="+MaskedValue, MaskString("password=my-file-secret"))
+}
@@ -4,31 +4,225 @@ import "github.com/mycontroller-org/server/v2/pkg/types/cmap"
 
 // Config
 type Config struct {
-	Logger   LoggerConfig    `yaml:"logger"`
-	Adapters []AdapterConfig `yaml:"adapters"`
+	Include    []string         `yaml:"include" json:"include"` // additional config files, glob patterns relative to the config file
+	Logger     LoggerConfig     `yaml:"logger" json:"logger"`
+	DataDir    string           `yaml:"data_dir" json:"data_dir"`
+	Reload     ReloadConfig     `yaml:"reload" json:"reload"`
+	HTTPServer HTTPServerConfig `yaml:"http_server" json:"http_server"`
+	Shutdown   ShutdownConfig   `yaml:"shutdown" json:"shutdown"`
+	DryRun     DryRunConfig     `yaml:"dry_run" json:"dry_run"` // applies to all the adapters
+	Adapters   []AdapterConfig  `yaml:"adapters" json:"adapters"`
 }
 
 // LoggerConfig struct
 type LoggerConfig struct {
-	Mode             string `yaml:"mode"`
-	Encoding         string `yaml:"encoding"`
-	Level            string `yaml:"level"`
-	EnableStacktrace bool   `yaml:"enable_stacktrace"`
+	Mode             string `yaml:"mode" json:"mode"`
+	Encoding         string `yaml:"encoding" json:"encoding"`
+	Level            string `yaml:"level" json:"level"`
+	EnableStacktrace bool   `yaml:"enable_stacktrace" json:"enable_stacktrace"`
+}
+
+// ReloadConfig struct, reloads the configuration on file change.
+// reload can be triggered with SIGHUP signal too
+type ReloadConfig struct {
+	WatchFile     bool   `yaml:"watch_file" json:"watch_file"`
+	WatchInterval string `yaml:"watch_interval" json:"watch_interval"`
+}
+
+// ShutdownConfig struct, queued messages will be written to the devices within the drain timeout.
+// the process will be terminated forcefully, if the services are not stopped within the grace period
+type ShutdownConfig struct {
+	GracePeriod  string `yaml:"grace_period" json:"grace_period"`
+	DrainTimeout string `yaml:"drain_timeout" json:"drain_timeout"`
+}
+
+// HTTPServerConfig struct, serves metrics, health and admin api endpoints
+type HTTPServerConfig struct {
+	Enabled       bool            `yaml:"enabled" json:"enabled"`
+	ListenAddress string          `yaml:"listen_address" json:"listen_address"`
+	Readiness     ReadinessConfig `yaml:"readiness" json:"readiness"`
+	API           APIConfig       `yaml:"api" json:"api"`
+}
+
+// APIConfig struct, admin api protected with bearer token or basic authentication
+type APIConfig struct {
+	Enabled  bool   `yaml:"enabled" json:"enabled"`
+	Username string `yaml:"username" json:"username"`
+	Password string `yaml:"password" json:"-"`
+	Token    string `yaml:"token" json:"-"`
+}
+
+// ReadinessConfig struct, adapters to be verified on readiness, if empty all the enabled adapters will be verified
+type ReadinessConfig struct {
+	Adapters []string `yaml:"adapters" json:"adapters"`
 }
 
 // AdapterConfig struct
 type AdapterConfig struct {
-	Name            string          `yaml:"name"`
-	Enabled         bool            `yaml:"enabled"`
-	ReconnectDelay  string          `yaml:"reconnect_delay"`
-	Provider        string          `yaml:"provider"`
-	Source          cmap.CustomMap  `yaml:"source"`
-	MQTT            cmap.CustomMap  `yaml:"mqtt"`
-	FormatterScript FormatterScript `yaml:"formatter_script"`
+	Name            string          `yaml:"name" json:"name"`
+	Enabled         bool            `yaml:"enabled" json:"enabled"`
+	ReconnectDelay  string          `yaml:"reconnect_delay" json:"reconnect_delay"`
+	ReconnectPolicy ReconnectPolicy `yaml:"reconnect_policy" json:"reconnect_policy"`
+	Provider        string          `yaml:"provider" json:"provider"`
+	Source          cmap.CustomMap  `yaml:"source" json:"source"`
+	MQTT            cmap.CustomMap  `yaml:"mqtt" json:"mqtt"`
+	FormatterScript FormatterScript `yaml:"formatter_script" json:"formatter_script"`
+	Buffer          BufferConfig    `yaml:"buffer" json:"buffer"`
+	Journal         JournalConfig   `yaml:"journal" json:"journal"`
+	RequestResponse RequestResponse `yaml:"request_response" json:"request_response"`
+	Queue           QueueConfig     `yaml:"queue" json:"queue"`
+	Capture         CaptureConfig   `yaml:"capture" json:"capture"`
+	Filters         FiltersConfig   `yaml:"filters" json:"filters"`
+	Pipeline        PipelineConfig  `yaml:"pipeline" json:"pipeline"`
+	DryRun          DryRunConfig    `yaml:"dry_run" json:"dry_run"`
+	Watchdog        WatchdogConfig  `yaml:"watchdog" json:"watchdog"`
+}
+
+// WatchdogConfig struct, reconnects the source device when no data received within the inactivity timeout.
+// if the probe defined, it is written to the source device on inactivity and waits for the probe timeout
+type WatchdogConfig struct {
+	Enabled           bool   `yaml:"enabled" json:"enabled"`
+	InactivityTimeout string `yaml:"inactivity_timeout" json:"inactivity_timeout"`
+	Probe             string `yaml:"probe" json:"probe"`
+	ProbeTimeout      string `yaml:"probe_timeout" json:"probe_timeout"`
+}
+
+// DryRunConfig struct, formatted messages are logged and published to the shadow topic instead of writing to the devices.
+// shadow topic receives "<shadow_topic>/to_source" and "<shadow_topic>/to_mqtt/<mqtt topic>"
+type DryRunConfig struct {
+	Enabled     bool   `yaml:"enabled" json:"enabled"`
+	ShadowTopic string `yaml:"shadow_topic" json:"shadow_topic"`
+}
+
+// Merge returns the adapter dry run config, enabled if enabled on any of the configs.
+// adapter shadow topic takes the precedence
+func (dr DryRunConfig) Merge(global DryRunConfig) DryRunConfig {
+	merged := DryRunConfig{
+		Enabled:     dr.Enabled || global.Enabled,
+		ShadowTopic: dr.ShadowTopic,
+	}
+	if merged.ShadowTopic == "" {
+		merged.ShadowTopic = global.ShadowTopic
+	}
+	return merged
+}
+
+// ReconnectPolicy defines the reconnect delay between the attempts.
+// delay grows from the initial delay by the multiplier up to the max delay, jitter is a fraction of the delay (0 to 1).
+// max attempts zero means retry forever, on exceeding the adapter device will be marked as failed
+type ReconnectPolicy struct {
+	InitialDelay string  `yaml:"initial_delay" json:"initial_delay"`
+	Multiplier   float64 `yaml:"multiplier" json:"multiplier"`
+	MaxDelay     string  `yaml:"max_delay" json:"max_delay"`
+	Jitter       float64 `yaml:"jitter" json:"jitter"`
+	MaxAttempts  int     `yaml:"max_attempts" json:"max_attempts"`
 }
 
 // enter formatter script details, will be used along with raw provider
 type FormatterScript struct {
-	ToSource string `yaml:"to_source"`
-	ToMQTT   string `yaml:"to_mqtt"`
+	ToSource string `yaml:"to_source" json:"to_source"`
+	ToMQTT   string `yaml:"to_mqtt" json:"to_mqtt"`
+}
+
+// buffer drop policies
+const (
+	BufferDropOldest = "drop_oldest"
+	BufferDropNewest = "drop_newest"
+)
+
+// BufferConfig holds the undelivered messages, while the target device is not available
+type BufferConfig struct {
+	Enabled    bool   `yaml:"enabled" json:"enabled"`
+	MaxCount   int    `yaml:"max_count" json:"max_count"`
+	MaxAge     string `yaml:"max_age" json:"max_age"`
+	DropPolicy string `yaml:"drop_policy" json:"drop_policy"`
+}
+
+// FiltersConfig of the messages on each direction
+type FiltersConfig struct {
+	ToMQTT   FilterConfig `yaml:"to_mqtt" json:"to_mqtt"`
+	ToSource FilterConfig `yaml:"to_source" json:"to_source"`
+}
+
+// FilterConfig allow and deny rules, topics are mqtt wildcard patterns and payloads are regular expressions.
+// deny rules take precedence, if allow rules defined a message should match any of them. size limits in bytes, zero means no limit
+type FilterConfig struct {
+	AllowTopics   []string `yaml:"allow_topics" json:"allow_topics"`
+	DenyTopics    []string `yaml:"deny_topics" json:"deny_topics"`
+	AllowPayloads []string `yaml:"allow_payloads" json:"allow_payloads"`
+	DenyPayloads  []string `yaml:"deny_payloads" json:"deny_payloads"`
+	MinSize       int      `yaml:"min_size" json:"min_size"`
+	MaxSize       int      `yaml:"max_size" json:"max_size"`
+}
+
+// PipelineConfig transformation stages on each direction
+type PipelineConfig struct {
+	ToMQTT   DirectionPipeline `yaml:"to_mqtt" json:"to_mqtt"`
+	ToSource DirectionPipeline `yaml:"to_source" json:"to_source"`
+}
+
+// DirectionPipeline stages executed before and after the provider formatter
+type DirectionPipeline struct {
+	Before []PipelineStage `yaml:"before" json:"before"`
+	After  []PipelineStage `yaml:"after" json:"after"`
+}
+
+// PipelineStage modifies the message data and others or drops the message.
+// types: script, regex_replace, template, json_extract, encoding, filter. fields are applicable based on the type
+type PipelineStage struct {
+	Type        string       `yaml:"type" json:"type"`
+	Name        string       `yaml:"name" json:"name"`
+	Script      string       `yaml:"script" json:"script"`
+	Pattern     string       `yaml:"pattern" json:"pattern"`
+	Replacement string       `yaml:"replacement" json:"replacement"`
+	Template    string       `yaml:"template" json:"template"`
+	Field       string       `yaml:"field" json:"field"`
+	OthersKey   string       `yaml:"others_key" json:"others_key"`
+	From        string       `yaml:"from" json:"from"`
+	To          string       `yaml:"to" json:"to"`
+	Filter      FilterConfig `yaml:"filter" json:"filter"`
+}
+
+// CaptureConfig records all the messages on both directions to rotating json lines files
+type CaptureConfig struct {
+	Enabled  bool   `yaml:"enabled" json:"enabled"`
+	Dir      string `yaml:"dir" json:"dir"`
+	MaxSize  int64  `yaml:"max_size" json:"max_size"`
+	MaxFiles int    `yaml:"max_files" json:"max_files"`
+}
+
+// QueueConfig of the message queues on each direction
+type QueueConfig struct {
+	ToMQTT   QueueSettings `yaml:"to_mqtt" json:"to_mqtt"`
+	ToSource QueueSettings `yaml:"to_source" json:"to_source"`
+}
+
+// QueueSettings capacity and the overflow policy, policies: block, drop_newest, drop_oldest, coalesce
+type QueueSettings struct {
+	Capacity       int    `yaml:"capacity" json:"capacity"`
+	OverflowPolicy string `yaml:"overflow_policy" json:"overflow_policy"`
+}
+
+// JournalConfig persists the messages received from the source device, until those are published to mqtt
+type JournalConfig struct {
+	Enabled       bool   `yaml:"enabled" json:"enabled"`
+	Dir           string `yaml:"dir" json:"dir"`
+	FsyncPolicy   string `yaml:"fsync_policy" json:"fsync_policy"`
+	FsyncInterval string `yaml:"fsync_interval" json:"fsync_interval"`
+	SegmentSize   int64  `yaml:"segment_size" json:"segment_size"`
+}
+
+// RequestResponse correlates a mqtt request with the reply from the source device.
+// a mqtt json message with the correlation field is a request, the payload field will be written to the source.
+// the next source message matches the rule within the timeout will be published on the response topic.
+// only the json fields are supported, mqtt v5 response topic and correlation data are not available on mqtt v3.1.1
+type RequestResponse struct {
+	Enabled            bool   `yaml:"enabled" json:"enabled"`
+	CorrelationField   string `yaml:"correlation_field" json:"correlation_field"`
+	ResponseTopicField string `yaml:"response_topic_field" json:"response_topic_field"`
+	PayloadField       string `yaml:"payload_field" json:"payload_field"`
+	ResponseTopic      string `yaml:"response_topic" json:"response_topic"`
+	MatchRegex         string `yaml:"match_regex" json:"match_regex"`
+	MatchScript        string `yaml:"match_script" json:"match_script"`
+	Timeout            string `yaml:"timeout" json:"timeout"`
 }
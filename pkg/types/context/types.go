@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 
+	cfgTY "github.com/mycontroller-org/2mqtt/pkg/types/config"
 	"go.uber.org/zap"
 )
 
@@ -29,3 +30,18 @@ func LoggerFromContext(ctx context.Context) (*zap.Logger, error) {
 func LoggerWithContext(ctx context.Context, logger *zap.Logger) context.Context {
 	return context.WithValue(ctx, LOGGER, logger)
 }
+
+func ConfigFromContext(ctx context.Context) (*cfgTY.Config, error) {
+	cfg, ok := ctx.Value(CONFIG).(*cfgTY.Config)
+	if !ok {
+		return nil, errors.New("invalid config instance received in context")
+	}
+	if cfg == nil {
+		return nil, errors.New("config instance not provided in context")
+	}
+	return cfg, nil
+}
+
+func ConfigWithContext(ctx context.Context, cfg *cfgTY.Config) context.Context {
+	return context.WithValue(ctx, CONFIG, cfg)
+}
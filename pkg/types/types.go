@@ -6,28 +6,42 @@ import (
 
 const (
 	// device types
-	DeviceEthernet = "ethernet"
-	DeviceSerial   = "serial"
-	DeviceMQTT     = "mqtt"
-	DeviceHTTP     = "http"
+	DeviceEthernet  = "ethernet"
+	DeviceSerial    = "serial"
+	DeviceMQTT      = "mqtt"
+	DeviceHTTP      = "http"
+	DeviceSimulator = "simulator"
 
 	// keys used across
-	KeyType            = "type"
-	KeyName            = "name"
-	KeyMqttTopic       = "mqtt_topic"
-	KeyMqttQoS         = "mqtt_qos"
-	KeyMessageSplitter = "message_splitter"
-	KeyHeaders         = "headers"
-	KeyURL             = "url"
+	KeyType              = "type"
+	KeyName              = "name"
+	KeyMqttTopic         = "mqtt_topic"
+	KeyMqttQoS           = "mqtt_qos"
+	KeyMqttRetain        = "mqtt_retain"
+	KeyMqttAbsoluteTopic = "mqtt_absolute_topic"
+	KeyMqttStatusTopic   = "status_topic"
+	KeyMessageSplitter   = "message_splitter"
+	KeyHeaders           = "headers"
+	KeyURL               = "url"
+	KeyJournalSequence   = "journal_sequence"
+	KeyCorrelationID     = "correlation_id"
+	KeyResponseTopic     = "response_topic"
 
 	// Status
-	StatusUP    = "up"
-	StatusError = "error"
+	StatusUP     = "up"
+	StatusError  = "error"  // reported by the devices on failures
+	StatusFailed = "failed" // reconnect attempts exhausted
+
+	// device lifecycle status, maintained by the adapter service
+	StatusStopped      = "stopped"      // not started or stopped
+	StatusConnecting   = "connecting"   // connection in progress, waiting for the device to be up
+	StatusDisconnected = "disconnected" // connection lost or failed
+	StatusReconnecting = "reconnecting" // reconnect scheduled
 )
 
 // State struct
 type State struct {
-	Status  string
-	Message string
-	Since   time.Time
+	Status  string    `json:"status"`
+	Message string    `json:"message"`
+	Since   time.Time `json:"since"`
 }
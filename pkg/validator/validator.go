@@ -0,0 +1,378 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/mycontroller-org/2mqtt/pkg/filter"
+	"github.com/mycontroller-org/2mqtt/pkg/pipeline"
+	"github.com/mycontroller-org/2mqtt/pkg/queue"
+	"github.com/mycontroller-org/2mqtt/pkg/types"
+	config "github.com/mycontroller-org/2mqtt/pkg/types/config"
+	contextTY "github.com/mycontroller-org/2mqtt/pkg/types/context"
+	devicePlugin "github.com/mycontroller-org/2mqtt/plugin/device"
+	mqttDevice "github.com/mycontroller-org/2mqtt/plugin/device/mqtt"
+	providerPlugin "github.com/mycontroller-org/2mqtt/plugin/provider"
+	"github.com/mycontroller-org/server/v2/pkg/types/cmap"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// supported mqtt broker url schemes
+var brokerSchemes = []string{"tcp", "mqtt", "tls", "mqtts", "ssl", "ws", "wss"}
+
+// duration keys on the source and mqtt configurations
+var (
+	sourceDurationKeys = []string{"transmit_pre_delay", "interval"}
+	mqttDurationKeys   = []string{"transmit_pre_delay", "reconnect_delay", "connection_timeout", "dedup_window"}
+)
+
+// Issue is a validation error
+type Issue struct {
+	File    string // available on the merged configuration
+	Line    int
+	Path    string
+	Message string
+}
+
+func (i Issue) String() string {
+	location := ""
+	if i.File != "" {
+		location = i.File + ": "
+	}
+	if i.Line > 0 {
+		location += fmt.Sprintf("line %d: ", i.Line)
+	}
+	if i.Path != "" {
+		location += i.Path + ": "
+	}
+	return location + i.Message
+}
+
+type validator struct {
+	ctx    context.Context
+	root   *yaml.Node
+	files  map[*yaml.Node]string
+	issues []Issue
+}
+
+// Validate parses the yaml configuration and returns the issues, empty if the configuration is valid
+func Validate(data []byte) []Issue {
+	document := &yaml.Node{}
+	if err := yaml.Unmarshal(data, document); err != nil {
+		return []Issue{{Message: err.Error()}}
+	}
+	if err := config.Substitute(document); err != nil {
+		return []Issue{{Message: err.Error()}}
+	}
+	if len(document.Content) == 0 {
+		return ValidateNode(nil, nil)
+	}
+	return ValidateNode(document.Content[0], nil)
+}
+
+// ValidateNode validates the substituted root node, used on the configuration merged from many files.
+// files maps the nodes to the source file, the issue reports the file of the nearest mapped node on the path
+func ValidateNode(root *yaml.Node, files map[*yaml.Node]string) []Issue {
+	cfg := &config.Config{}
+	if root != nil {
+		if err := root.Decode(cfg); err != nil {
+			return []Issue{{Message: err.Error()}}
+		}
+	}
+
+	v := &validator{ctx: contextTY.LoggerWithContext(context.Background(), zap.NewNop()), root: root, files: files}
+
+	v.duration(cfg.Reload.WatchInterval, "reload", "watch_interval")
+	v.duration(cfg.Shutdown.GracePeriod, "shutdown", "grace_period")
+	v.duration(cfg.Shutdown.DrainTimeout, "shutdown", "drain_timeout")
+
+	names := make(map[string]int)
+	serialPorts := make(map[string]string)
+	listenAddresses := make(map[string]string)
+	if cfg.HTTPServer.Enabled && cfg.HTTPServer.ListenAddress != "" {
+		listenAddresses[cfg.HTTPServer.ListenAddress] = "http_server"
+	}
+
+	for index := range cfg.Adapters {
+		adapterCfg := cfg.Adapters[index]
+		path := []interface{}{"adapters", index}
+
+		if adapterCfg.Name == "" {
+			v.add(path, "name can not be empty")
+		} else if previous, found := names[adapterCfg.Name]; found {
+			v.add(append(path, "name"), "duplicate adapter name [%s], already defined on adapters[%d]", adapterCfg.Name, previous)
+		} else {
+			names[adapterCfg.Name] = index
+		}
+
+		v.validateAdapter(path, adapterCfg)
+
+		// shared resources, verified only on the enabled adapters
+		if !adapterCfg.Enabled {
+			continue
+		}
+		sourcePath := append(path, "source")
+		switch adapterCfg.Source.GetString(types.KeyType) {
+		case types.DeviceSerial:
+			port := adapterCfg.Source.GetString("port")
+			if owner, found := serialPorts[port]; found && port != "" {
+				v.add(append(sourcePath, "port"), "serial port [%s] already used on %s", port, owner)
+			}
+			serialPorts[port] = pathString(path)
+		case types.DeviceHTTP:
+			address := adapterCfg.Source.GetString("listen_address")
+			if owner, found := listenAddresses[address]; found && address != "" {
+				v.add(append(sourcePath, "listen_address"), "listen address [%s] already used on %s", address, owner)
+			}
+			listenAddresses[address] = pathString(path)
+		}
+	}
+
+	// stable output, some of the checks iterate over maps
+	sort.SliceStable(v.issues, func(i, j int) bool {
+		a, b := v.issues[i], v.issues[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Path < b.Path
+	})
+	return v.issues
+}
+
+func (v *validator) validateAdapter(path []interface{}, adapterCfg config.AdapterConfig) {
+	v.duration(adapterCfg.ReconnectDelay, append(path, "reconnect_delay")...)
+	v.duration(adapterCfg.ReconnectPolicy.InitialDelay, append(path, "reconnect_policy", "initial_delay")...)
+	v.duration(adapterCfg.ReconnectPolicy.MaxDelay, append(path, "reconnect_policy", "max_delay")...)
+	v.duration(adapterCfg.Buffer.MaxAge, append(path, "buffer", "max_age")...)
+	v.duration(adapterCfg.Journal.FsyncInterval, append(path, "journal", "fsync_interval")...)
+	v.duration(adapterCfg.RequestResponse.Timeout, append(path, "request_response", "timeout")...)
+	v.duration(adapterCfg.Watchdog.InactivityTimeout, append(path, "watchdog", "inactivity_timeout")...)
+	v.duration(adapterCfg.Watchdog.ProbeTimeout, append(path, "watchdog", "probe_timeout")...)
+
+	if dropPolicy := adapterCfg.Buffer.DropPolicy; dropPolicy != "" && dropPolicy != config.BufferDropOldest && dropPolicy != config.BufferDropNewest {
+		v.add(append(path, "buffer", "drop_policy"), "unsupported drop policy [%s]", dropPolicy)
+	}
+
+	// source and provider
+	sourcePath := append(path, "source")
+	sourceType := adapterCfg.Source.GetString(types.KeyType)
+	if sourceType == "" {
+		v.add(sourcePath, "source type can not be empty")
+	} else if !devicePlugin.IsRegistered(sourceType) {
+		v.add(append(sourcePath, types.KeyType), "unsupported source type [%s]", sourceType)
+	} else {
+		// providers verify the supported source types, source config is cloned as providers update it
+		sourceCfg := make(cmap.CustomMap)
+		for key, value := range adapterCfg.Source {
+			sourceCfg[key] = value
+		}
+		if _, err := providerPlugin.Create(v.ctx, adapterCfg.Provider, sourceCfg, adapterCfg.FormatterScript); err != nil {
+			v.add(append(path, "provider"), "%s", err)
+		}
+	}
+	for _, key := range sourceDurationKeys {
+		v.duration(adapterCfg.Source.GetString(key), append(sourcePath, key)...)
+	}
+	if sourceType == types.DeviceSimulator {
+		v.script(adapterCfg.Source.GetString("script"), append(sourcePath, "script")...)
+	}
+
+	// mqtt
+	v.validateMqtt(append(path, "mqtt"), adapterCfg.MQTT)
+
+	// scripts
+	v.script(adapterCfg.FormatterScript.ToSource, append(path, "formatter_script", "to_source")...)
+	v.script(adapterCfg.FormatterScript.ToMQTT, append(path, "formatter_script", "to_mqtt")...)
+	v.script(adapterCfg.RequestResponse.MatchScript, append(path, "request_response", "match_script")...)
+	if expression := adapterCfg.RequestResponse.MatchRegex; expression != "" {
+		if _, err := regexp.Compile(expression); err != nil {
+			v.add(append(path, "request_response", "match_regex"), "%s", err)
+		}
+	}
+	if rr := adapterCfg.RequestResponse; rr.Enabled && rr.MatchRegex == "" && rr.MatchScript == "" {
+		v.add(append(path, "request_response"), "match_regex or match_script required, otherwise any source message is taken as the reply")
+	}
+
+	// queues
+	if _, err := queue.New("", adapterCfg.Queue.ToMQTT.Capacity, adapterCfg.Queue.ToMQTT.OverflowPolicy); err != nil {
+		v.add(append(path, "queue", "to_mqtt", "overflow_policy"), "%s", err)
+	}
+	if _, err := queue.New("", adapterCfg.Queue.ToSource.Capacity, adapterCfg.Queue.ToSource.OverflowPolicy); err != nil {
+		v.add(append(path, "queue", "to_source", "overflow_policy"), "%s", err)
+	}
+
+	// filters
+	if _, err := filter.New(adapterCfg.Filters.ToMQTT); err != nil {
+		v.add(append(path, "filters", "to_mqtt"), "%s", err)
+	}
+	if _, err := filter.New(adapterCfg.Filters.ToSource); err != nil {
+		v.add(append(path, "filters", "to_source"), "%s", err)
+	}
+
+	// pipelines
+	pipelines := map[string][]config.PipelineStage{
+		"to_mqtt.before":   adapterCfg.Pipeline.ToMQTT.Before,
+		"to_mqtt.after":    adapterCfg.Pipeline.ToMQTT.After,
+		"to_source.before": adapterCfg.Pipeline.ToSource.Before,
+		"to_source.after":  adapterCfg.Pipeline.ToSource.After,
+	}
+	for name, stages := range pipelines {
+		direction, position, _ := strings.Cut(name, ".")
+		for index, stage := range stages {
+			stagePath := append(path, "pipeline", direction, position, index)
+			if _, err := pipeline.New(zap.NewNop(), []config.PipelineStage{stage}); err != nil {
+				v.add(stagePath, "%s", err)
+				continue
+			}
+			if stage.Type == pipeline.StageScript {
+				v.script(stage.Script, append(stagePath, "script")...)
+			}
+		}
+	}
+}
+
+func (v *validator) validateMqtt(path []interface{}, mqttCfg cmap.CustomMap) {
+	for _, key := range mqttDurationKeys {
+		v.duration(mqttCfg.GetString(key), append(path, key)...)
+	}
+
+	brokers, hasBrokers := mqttCfg[mqttDevice.KeyBrokers]
+	if !hasBrokers {
+		v.broker(mqttCfg.GetString("broker"), append(path, "broker")...)
+		return
+	}
+
+	if mode := mqttCfg.GetString(mqttDevice.KeyMode); mode != "" && mode != mqttDevice.ModeFailover && mode != mqttDevice.ModeFanout {
+		v.add(append(path, mqttDevice.KeyMode), "unsupported mode [%s], options: %s, %s", mode, mqttDevice.ModeFailover, mqttDevice.ModeFanout)
+	}
+	items, ok := brokers.([]interface{})
+	if !ok || len(items) == 0 {
+		v.add(append(path, mqttDevice.KeyBrokers), "brokers should be a non empty list")
+		return
+	}
+	for index, item := range items {
+		brokerPath := append(path, mqttDevice.KeyBrokers, index)
+		switch typedItem := item.(type) {
+		case string:
+			v.broker(typedItem, brokerPath...)
+		case map[string]interface{}:
+			v.broker(cmap.CustomMap(typedItem).GetString("broker"), append(brokerPath, "broker")...)
+			for _, key := range mqttDurationKeys {
+				v.duration(cmap.CustomMap(typedItem).GetString(key), append(brokerPath, key)...)
+			}
+		default:
+			v.add(brokerPath, "invalid broker config")
+		}
+	}
+}
+
+func (v *validator) broker(broker string, path ...interface{}) {
+	if broker == "" {
+		v.add(path, "broker can not be empty")
+		return
+	}
+	brokerURL, err := url.Parse(broker)
+	if err != nil {
+		v.add(path, "%s", err)
+		return
+	}
+	for _, scheme := range brokerSchemes {
+		if brokerURL.Scheme == scheme {
+			return
+		}
+	}
+	v.add(path, "unsupported broker url scheme [%s], options: %s", brokerURL.Scheme, strings.Join(brokerSchemes, ", "))
+}
+
+func (v *validator) duration(value string, path ...interface{}) {
+	if value == "" {
+		return
+	}
+	if _, err := time.ParseDuration(value); err != nil {
+		v.add(path, "invalid duration [%s]", value)
+	}
+}
+
+func (v *validator) script(script string, path ...interface{}) {
+	if script == "" {
+		return
+	}
+	if _, err := goja.Compile("", script, false); err != nil {
+		v.add(path, "script compile error: %s", err)
+	}
+}
+
+func (v *validator) add(path []interface{}, format string, args ...interface{}) {
+	line, file := v.locate(path)
+	v.issues = append(v.issues, Issue{
+		File:    file,
+		Line:    line,
+		Path:    pathString(path),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// locate returns the line of the node on the path, falls back to the nearest available parent.
+// file is taken from the nearest mapped node
+func (v *validator) locate(path []interface{}) (int, string) {
+	node := v.root
+	if node == nil {
+		return 0, ""
+	}
+	line, file := node.Line, v.files[node]
+	for _, element := range path {
+		var next *yaml.Node
+		switch typedElement := element.(type) {
+		case string:
+			if node.Kind == yaml.MappingNode {
+				for index := 0; index+1 < len(node.Content); index += 2 {
+					if node.Content[index].Value == typedElement {
+						line = node.Content[index].Line
+						next = node.Content[index+1]
+						break
+					}
+				}
+			}
+		case int:
+			if node.Kind == yaml.SequenceNode && typedElement < len(node.Content) {
+				next = node.Content[typedElement]
+				line = next.Line
+			}
+		}
+		if next == nil {
+			return line, file
+		}
+		node = next
+		if nodeFile, found := v.files[node]; found {
+			file = nodeFile
+		}
+	}
+	return line, file
+}
+
+// pathString returns the path as "adapters[0].source.type"
+func pathString(path []interface{}) string {
+	builder := strings.Builder{}
+	for _, element := range path {
+		switch typedElement := element.(type) {
+		case int:
+			fmt.Fprintf(&builder, "[%d]", typedElement)
+		default:
+			if builder.Len() > 0 {
+				builder.WriteString(".")
+			}
+			fmt.Fprintf(&builder, "%v", typedElement)
+		}
+	}
+	return builder.String()
+}
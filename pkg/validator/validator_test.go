@@ -0,0 +1,166 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		expected []Issue
+	}{
+		{
+			name: "valid",
+			config: `
+adapters:
+  - name: adapter1
+    enabled: true
+    reconnect_delay: 20s
+    provider: raw
+    source:
+      type: serial
+      port: /dev/ttyUSB0
+    mqtt:
+      broker: tcp://127.0.0.1:1883
+    formatter_script:
+      to_mqtt: result = raw_data
+`,
+			expected: nil,
+		},
+		{
+			name: "invalid_values",
+			config: `
+adapters:
+  - name: adapter1
+    enabled: true
+    reconnect_delay: 20 seconds
+    provider: mysensors_v2
+    source:
+      type: http
+    mqtt:
+      broker: http://127.0.0.1:1883
+    formatter_script:
+      to_mqtt: result = {
+`,
+			expected: []Issue{
+				{Line: 5, Path: "adapters[0].reconnect_delay", Message: "invalid duration [20 seconds]"},
+				{Line: 6, Path: "adapters[0].provider", Message: "unsupported source type:http"},
+				{Line: 10, Path: "adapters[0].mqtt.broker", Message: "unsupported broker url scheme [http], options: tcp, mqtt, tls, mqtts, ssl, ws, wss"},
+			},
+		},
+		{
+			name: "duplicates",
+			config: `
+adapters:
+  - name: adapter1
+    enabled: true
+    provider: raw
+    source:
+      type: serial
+      port: /dev/ttyUSB0
+    mqtt:
+      broker: tcp://127.0.0.1:1883
+  - name: adapter1
+    enabled: true
+    provider: raw
+    source:
+      type: serial
+      port: /dev/ttyUSB0
+    mqtt:
+      broker: tcp://127.0.0.1:1883
+`,
+			expected: []Issue{
+				{Line: 11, Path: "adapters[1].name", Message: "duplicate adapter name [adapter1], already defined on adapters[0]"},
+				{Line: 16, Path: "adapters[1].source.port", Message: "serial port [/dev/ttyUSB0] already used on adapters[0]"},
+			},
+		},
+		{
+			name: "parent_line",
+			config: `
+adapters:
+  - name: adapter1
+    provider: raw
+    source:
+      type: serial
+    mqtt:
+      client_id: client1
+    queue:
+      to_mqtt:
+        overflow_policy: drop_all
+    request_response:
+      enabled: true
+`,
+			expected: []Issue{
+				{Line: 7, Path: "adapters[0].mqtt.broker", Message: "broker can not be empty"},
+				{Line: 11, Path: "adapters[0].queue.to_mqtt.overflow_policy", Message: "unsupported queue overflow policy [drop_all]"},
+				{Line: 12, Path: "adapters[0].request_response", Message: "match_regex or match_script required, otherwise any source message is taken as the reply"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			issues := Validate([]byte(test.config))
+			if test.name == "invalid_values" {
+				// script compile error message is owned by the javascript engine
+				assert.Len(t, issues, len(test.expected)+1)
+				assert.Equal(t, 12, issues[len(issues)-1].Line)
+				assert.Equal(t, "adapters[0].formatter_script.to_mqtt", issues[len(issues)-1].Path)
+				issues = issues[:len(issues)-1]
+			}
+			assert.Equal(t, test.expected, issues)
+		})
+	}
+}
+
+func TestIssueString(t *testing.T) {
+	tests := []struct {
+		issue    Issue
+		expected string
+	}{
+		{issue: Issue{Message: "invalid"}, expected: "invalid"},
+		{issue: Issue{Line: 3, Path: "adapters[0].name", Message: "invalid"}, expected: "line 3: adapters[0].name: invalid"},
+		{issue: Issue{File: "conf.d/a.yaml", Line: 3, Path: "adapters[0].name", Message: "invalid"}, expected: "conf.d/a.yaml: line 3: adapters[0].name: invalid"},
+		{issue: Issue{File: "conf.d/a.yaml", Message: "invalid"}, expected: "conf.d/a.yaml: invalid"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expected, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.issue.String())
+		})
+	}
+}
+
+func TestValidateStableOrder(t *testing.T) {
+	config := `
+adapters:
+  - name: adapter1
+    provider: raw
+    source:
+      type: serial
+    mqtt:
+      broker: tcp://127.0.0.1:1883
+    pipeline:
+      to_mqtt:
+        before:
+          - type: unknown
+        after:
+          - type: unknown
+      to_source:
+        before:
+          - type: unknown
+        after:
+          - type: unknown
+`
+	expected := Validate([]byte(config))
+	assert.Len(t, expected, 4)
+	for index := 1; index < len(expected); index++ {
+		assert.Less(t, expected[index-1].Line, expected[index].Line)
+	}
+	for count := 0; count < 10; count++ {
+		assert.Equal(t, expected, Validate([]byte(config)))
+	}
+}
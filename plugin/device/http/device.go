@@ -8,6 +8,7 @@ import (
 	"time"
 
 	model "github.com/mycontroller-org/2mqtt/pkg/types"
+	cfgTY "github.com/mycontroller-org/2mqtt/pkg/types/config"
 	contextTY "github.com/mycontroller-org/2mqtt/pkg/types/context"
 	deviceType "github.com/mycontroller-org/2mqtt/plugin/device/types"
 	"github.com/mycontroller-org/server/v2/pkg/types/cmap"
@@ -56,7 +57,7 @@ func NewDevice(ctx context.Context, ID string, config cmap.CustomMap, rxFunc fun
 		return nil, err
 	}
 
-	logger.Debug("source device config", zap.String("id", ID), zap.Any("config", cfg))
+	logger.Debug("source device config", zap.String("id", ID), zap.Any("config", cfgTY.Mask(cfg)))
 
 	logger.Info("opening the listening address", zap.String("adapterName", ID), zap.String("listenAddress", cfg.ListenAddress))
 	listener, err := net.Listen("tcp", cfg.ListenAddress)
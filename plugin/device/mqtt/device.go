@@ -9,6 +9,7 @@ import (
 
 	paho "github.com/eclipse/paho.mqtt.golang"
 	model "github.com/mycontroller-org/2mqtt/pkg/types"
+	cfgTY "github.com/mycontroller-org/2mqtt/pkg/types/config"
 	contextTY "github.com/mycontroller-org/2mqtt/pkg/types/context"
 	deviceType "github.com/mycontroller-org/2mqtt/plugin/device/types"
 	"github.com/mycontroller-org/server/v2/pkg/types/cmap"
@@ -24,6 +25,9 @@ const (
 	transmitPreDelayDefault  = time.Microsecond * 1 // 1 micro second
 	reconnectDelayDefault    = time.Second * 10     // 10 seconds
 	connectionTimeoutDefault = time.Second * 30     // 30 seconds
+
+	payloadOnlineDefault  = "online"
+	payloadOfflineDefault = "offline"
 )
 
 // Config struct
@@ -39,6 +43,10 @@ type Config struct {
 	TransmitPreDelay  string `yaml:"transmit_pre_delay"`
 	ReconnectDelay    string `yaml:"reconnect_delay"`
 	ConnectionTimeout string `yaml:"connection_timeout"`
+	AvailabilityTopic string `yaml:"availability_topic"`
+	PayloadOnline     string `yaml:"payload_online"`
+	PayloadOffline    string `yaml:"payload_offline"`
+	StatusTopic       string `yaml:"status_topic"`
 }
 
 // Endpoint data
@@ -52,28 +60,63 @@ type Endpoint struct {
 	txPreDelay     time.Duration
 }
 
-// NewDevice mqtt driver
+// NewDevice mqtt driver, creates multi broker device if brokers list supplied
 func NewDevice(ctx context.Context, ID string, config cmap.CustomMap, rxFunc func(msg *model.Message), statusFunc func(state *model.State)) (deviceType.Plugin, error) {
 	logger, err := contextTY.LoggerFromContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	if _, found := config[KeyBrokers]; found {
+		return newMultiDevice(logger, ID, config, rxFunc, statusFunc)
+	}
+
 	start := time.Now()
 
-	var cfg Config
-	err = utils.MapToStruct(utils.TagNameYaml, config, &cfg)
+	cfg, err := toConfig(config)
 	if err != nil {
 		logger.Error("error on converting map to struct", zap.Error(err))
 		return nil, err
 	}
-	logger.Debug("mqtt config", zap.Any("adapterName", ID), zap.Any("config", cfg))
+	logger.Debug("mqtt config", zap.Any("adapterName", ID), zap.Any("config", cfgTY.Mask(cfg)))
+
+	endpoint := newEndpoint(logger, ID, cfg, rxFunc, statusFunc, false)
+
+	endpoint.logger.Debug("mqtt client connecting to broker", zap.Any("adapterName", ID), zap.Any("clientConfig", cfgTY.Mask(cfg)))
+	token := endpoint.Client.Connect()
+	for !token.WaitTimeout(3 * time.Second) {
+	}
+	if err := token.Error(); err != nil {
+		return nil, err
+	}
+
+	endpoint.logger.Debug("mqtt client connected successfully", zap.Any("adapterName", ID), zap.String("timeTaken", time.Since(start).String()), zap.Any("clientConfig", cfgTY.Mask(cfg)))
+	return endpoint, nil
+}
+
+// converts the map to config and updates the defaults
+func toConfig(config cmap.CustomMap) (*Config, error) {
+	var cfg Config
+	err := utils.MapToStruct(utils.TagNameYaml, config, &cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.PayloadOnline == "" {
+		cfg.PayloadOnline = payloadOnlineDefault
+	}
+	if cfg.PayloadOffline == "" {
+		cfg.PayloadOffline = payloadOfflineDefault
+	}
+	return &cfg, nil
+}
 
-	// endpoint
+// newEndpoint creates an endpoint with the client, but not connected.
+// with connectRetry, the client keeps trying the initial connection in the background
+func newEndpoint(logger *zap.Logger, ID string, cfg *Config, rxFunc func(msg *model.Message), statusFunc func(state *model.State), connectRetry bool) *Endpoint {
 	endpoint := &Endpoint{
 		logger:         logger.Named("mqtt_client"),
 		ID:             ID,
-		Config:         &cfg,
+		Config:         cfg,
 		receiveMsgFunc: rxFunc,
 		statusFunc:     statusFunc,
 		txPreDelay:     utils.ToDuration(cfg.TransmitPreDelay, transmitPreDelayDefault),
@@ -86,27 +129,23 @@ func NewDevice(ctx context.Context, ID string, config cmap.CustomMap, rxFunc fun
 	opts.SetClientID(utils.RandID())
 	opts.SetCleanSession(false)
 	opts.SetAutoReconnect(true)
+	opts.SetConnectRetry(connectRetry)
 	opts.SetConnectRetryInterval(utils.ToDuration(cfg.ReconnectDelay, reconnectDelayDefault))
 	opts.SetOnConnectHandler(endpoint.onConnectionHandler)
 	opts.SetConnectionLostHandler(endpoint.onConnectionLostHandler)
 	opts.SetConnectTimeout(utils.ToDuration(cfg.ConnectionTimeout, connectionTimeoutDefault))
 
+	// last will and testament, broker publishes offline payload on unexpected disconnect
+	if cfg.AvailabilityTopic != "" {
+		opts.SetWill(cfg.AvailabilityTopic, cfg.PayloadOffline, byte(cfg.QoS), true)
+	}
+
 	// update tls config
 	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.Insecure}
 	opts.SetTLSConfig(tlsConfig)
 
 	endpoint.Client = paho.NewClient(opts)
-
-	endpoint.logger.Debug("mqtt client connecting to broker", zap.Any("adapterName", ID), zap.Any("clientConfig", cfg))
-	token := endpoint.Client.Connect()
-	for !token.WaitTimeout(3 * time.Second) {
-	}
-	if err := token.Error(); err != nil {
-		return nil, err
-	}
-
-	endpoint.logger.Debug("mqtt client connected successfully", zap.Any("adapterName", ID), zap.String("timeTaken", time.Since(start).String()), zap.Any("clientConfig", cfg))
-	return endpoint, nil
+	return endpoint
 }
 
 func (ep *Endpoint) Name() string {
@@ -121,6 +160,9 @@ func (ep *Endpoint) onConnectionHandler(c paho.Client) {
 		ep.logger.Error("error on subscribe topics", zap.Any("adapterName", ep.ID), zap.String("topics", ep.Config.Subscribe), zap.Error(err))
 	}
 
+	// birth message
+	ep.publishAvailability(ep.Config.PayloadOnline)
+
 	ep.statusFunc(&model.State{
 		Status:  model.StatusUP,
 		Message: "",
@@ -148,17 +190,24 @@ func (ep *Endpoint) Write(message *model.Message) error {
 	ep.logger.Debug("about to send a message", zap.Any("adapterName", ep.ID), zap.String("message", message.ToString()))
 	topic := message.Others.GetString(model.KeyMqttTopic)
 	qos := byte(ep.Config.QoS)
+	retain := toBool(message.Others.Get(model.KeyMqttRetain))
 
 	if ep.txPreDelay > 0 {
 		time.Sleep(ep.txPreDelay) // transmit pre delay
 	}
 
+	// publish as is, without publish topic prefix
+	if absoluteTopic := message.Others.GetString(model.KeyMqttAbsoluteTopic); absoluteTopic != "" {
+		token := ep.Client.Publish(absoluteTopic, qos, retain, string(message.Data))
+		return token.Error()
+	}
+
 	for _, rawtopic := range strings.Split(ep.Config.Publish, ",") {
 		_topic := strings.TrimSpace(rawtopic)
 		if topic != "" {
 			_topic = fmt.Sprintf("%s/%s", _topic, topic)
 		}
-		token := ep.Client.Publish(_topic, qos, false, string(message.Data))
+		token := ep.Client.Publish(_topic, qos, retain, string(message.Data))
 		if token.Error() != nil {
 			return token.Error()
 		}
@@ -166,13 +215,42 @@ func (ep *Endpoint) Write(message *model.Message) error {
 	return nil
 }
 
+// publishes retained availability payload, if availability topic configured
+func (ep *Endpoint) publishAvailability(payload string) {
+	if ep.Config.AvailabilityTopic == "" {
+		return
+	}
+	token := ep.Client.Publish(ep.Config.AvailabilityTopic, byte(ep.Config.QoS), true, payload)
+	if !token.WaitTimeout(3*time.Second) || token.Error() != nil {
+		ep.logger.Error("error on publishing availability", zap.String("adapterName", ep.ID), zap.String("topic", ep.Config.AvailabilityTopic), zap.String("payload", payload), zap.Error(token.Error()))
+	}
+}
+
+// converts the retain flag, supports bool, number and string
+func toBool(value interface{}) bool {
+	switch typedValue := value.(type) {
+	case bool:
+		return typedValue
+	case int, int64, float64:
+		return fmt.Sprintf("%v", typedValue) != "0"
+	case string:
+		normalized := strings.ToLower(strings.TrimSpace(typedValue))
+		return normalized == "true" || normalized == "1"
+	default:
+		return false
+	}
+}
+
 // Close the driver
 func (ep *Endpoint) Close() error {
 	if ep.Client.IsConnected() {
+		// will message is not published on graceful disconnect
+		ep.publishAvailability(ep.Config.PayloadOffline)
 		ep.Client.Unsubscribe(ep.Config.Subscribe)
-		ep.Client.Disconnect(0)
-		ep.logger.Debug("mqtt client connection closed", zap.String("adapterName", ep.ID))
 	}
+	// disconnect stops the background connection attempts too
+	ep.Client.Disconnect(0)
+	ep.logger.Debug("mqtt client connection closed", zap.String("adapterName", ep.ID), zap.String("broker", ep.Config.Broker))
 	return nil
 }
 
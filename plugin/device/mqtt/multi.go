@@ -0,0 +1,334 @@
+package mqtt
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	model "github.com/mycontroller-org/2mqtt/pkg/types"
+	"github.com/mycontroller-org/server/v2/pkg/types/cmap"
+	"github.com/mycontroller-org/server/v2/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// multi broker keys and modes
+const (
+	KeyBrokers     = "brokers"
+	KeyMode        = "mode"
+	KeyDedupWindow = "dedup_window"
+
+	ModeFailover = "failover" // uses the first connected broker, in the order of the list
+	ModeFanout   = "fanout"   // publishes to all the brokers, receives from all with de-duplication
+
+	dedupWindowDefault = time.Second * 2
+)
+
+// MultiEndpoint holds connections to more than one broker
+type MultiEndpoint struct {
+	logger         *zap.Logger
+	ID             string
+	mode           string
+	endpoints      []*Endpoint
+	connected      []bool
+	active         int // active endpoint index on failover mode, -1 if none connected
+	started        bool
+	mutex          sync.RWMutex
+	receiveMsgFunc func(msg *model.Message)
+	statusFunc     func(state *model.State)
+	dedup          *deduplicator
+}
+
+// newMultiDevice connects to all the brokers, returns error if none of them connected within the connection timeout.
+// the brokers those are not reachable will be retried in the background
+func newMultiDevice(logger *zap.Logger, ID string, config cmap.CustomMap, rxFunc func(msg *model.Message), statusFunc func(state *model.State)) (*MultiEndpoint, error) {
+	mode := config.GetString(KeyMode)
+	if mode == "" {
+		mode = ModeFailover
+	}
+	if mode != ModeFailover && mode != ModeFanout {
+		return nil, fmt.Errorf("unsupported mqtt mode [%s], supported modes: %s, %s", mode, ModeFailover, ModeFanout)
+	}
+
+	brokerConfigs, err := toBrokerConfigs(config)
+	if err != nil {
+		return nil, err
+	}
+
+	me := &MultiEndpoint{
+		logger:         logger.Named("mqtt_multi_client"),
+		ID:             ID,
+		mode:           mode,
+		connected:      make([]bool, len(brokerConfigs)),
+		active:         -1,
+		receiveMsgFunc: rxFunc,
+		statusFunc:     statusFunc,
+		dedup:          newDeduplicator(utils.ToDuration(config.GetString(KeyDedupWindow), dedupWindowDefault)),
+	}
+
+	connectionTimeout := connectionTimeoutDefault
+	for index, brokerConfig := range brokerConfigs {
+		cfg, err := toConfig(brokerConfig)
+		if err != nil {
+			logger.Error("error on converting map to struct", zap.String("adapterName", ID), zap.Int("brokerIndex", index), zap.Error(err))
+			return nil, err
+		}
+		if timeout := utils.ToDuration(cfg.ConnectionTimeout, connectionTimeoutDefault); timeout > connectionTimeout {
+			connectionTimeout = timeout
+		}
+		endpointIndex := index
+		endpoint := newEndpoint(logger, ID, cfg, me.receiveFunc(endpointIndex), me.endpointStatusFunc(endpointIndex), true)
+		me.endpoints = append(me.endpoints, endpoint)
+	}
+
+	me.logger.Debug("mqtt clients connecting to brokers", zap.String("adapterName", ID), zap.String("mode", mode), zap.Int("brokers", len(me.endpoints)))
+	for _, endpoint := range me.endpoints {
+		endpoint.Client.Connect()
+	}
+
+	// wait until a broker connected
+	deadline := time.Now().Add(connectionTimeout)
+	for !me.isConnected() {
+		if time.Now().After(deadline) {
+			_ = me.Close()
+			return nil, errors.New("none of the mqtt brokers connected")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	me.mutex.Lock()
+	me.started = true
+	me.mutex.Unlock()
+
+	// status changes are reported only after start, report the initial status
+	statusFunc(&model.State{
+		Status: model.StatusUP,
+		Since:  time.Now(),
+	})
+
+	return me, nil
+}
+
+// toBrokerConfigs merges the common config with each broker config, broker config takes precedence
+func toBrokerConfigs(config cmap.CustomMap) ([]cmap.CustomMap, error) {
+	items, ok := config[KeyBrokers].([]interface{})
+	if !ok || len(items) == 0 {
+		return nil, errors.New("mqtt brokers should be a non empty list")
+	}
+
+	brokerConfigs := make([]cmap.CustomMap, 0, len(items))
+	for index, item := range items {
+		var brokerConfig map[string]interface{}
+		switch typedItem := item.(type) {
+		case cmap.CustomMap:
+			brokerConfig = typedItem
+		case map[string]interface{}:
+			brokerConfig = typedItem
+		case string:
+			brokerConfig = map[string]interface{}{"broker": typedItem}
+		default:
+			return nil, fmt.Errorf("invalid mqtt broker config at index %d", index)
+		}
+
+		merged := make(cmap.CustomMap)
+		for key, value := range config {
+			if key == KeyBrokers || key == KeyMode || key == KeyDedupWindow {
+				continue
+			}
+			merged[key] = value
+		}
+		for key, value := range brokerConfig {
+			merged[key] = value
+		}
+		brokerConfigs = append(brokerConfigs, merged)
+	}
+	return brokerConfigs, nil
+}
+
+func (me *MultiEndpoint) Name() string {
+	return PluginMQTT
+}
+
+// returns true if any of the brokers connected
+func (me *MultiEndpoint) isConnected() bool {
+	me.mutex.RLock()
+	defer me.mutex.RUnlock()
+	for _, connected := range me.connected {
+		if connected {
+			return true
+		}
+	}
+	return false
+}
+
+// endpointStatusFunc tracks the status of a broker and reports the overall status to the adapter
+func (me *MultiEndpoint) endpointStatusFunc(index int) func(state *model.State) {
+	return func(state *model.State) {
+		if state == nil {
+			return
+		}
+
+		me.mutex.Lock()
+		wasConnected := me.active != -1
+		me.connected[index] = state.Status == model.StatusUP
+		previousActive := me.active
+		me.active = -1
+		for endpointIndex, connected := range me.connected {
+			if connected {
+				me.active = endpointIndex
+				break
+			}
+		}
+		isConnected := me.active != -1
+		started := me.started
+		me.mutex.Unlock()
+
+		if me.mode == ModeFailover && previousActive != me.active && me.active != -1 {
+			me.logger.Info("switched active mqtt broker", zap.String("adapterName", me.ID), zap.String("broker", me.endpoints[me.active].Config.Broker))
+		}
+
+		// report only the overall status change, the adapter reconnects all the brokers when none of them available
+		if started && wasConnected != isConnected {
+			me.statusFunc(state)
+		}
+	}
+}
+
+// receiveFunc filters the messages from the standby brokers and the duplicates
+func (me *MultiEndpoint) receiveFunc(index int) func(msg *model.Message) {
+	return func(msg *model.Message) {
+		if me.mode == ModeFailover {
+			me.mutex.RLock()
+			active := me.active
+			me.mutex.RUnlock()
+			if index != active {
+				return
+			}
+		} else if me.dedup.IsDuplicate(index, msg.Others.GetString(model.KeyMqttTopic), msg.Data) {
+			return
+		}
+		me.receiveMsgFunc(msg)
+	}
+}
+
+// Write publishes the message on the active broker on failover mode and on all the connected brokers on fanout mode
+func (me *MultiEndpoint) Write(message *model.Message) error {
+	if message == nil {
+		return nil
+	}
+
+	me.mutex.RLock()
+	active := me.active
+	connected := append([]bool{}, me.connected...)
+	me.mutex.RUnlock()
+
+	if active == -1 {
+		return errors.New("none of the mqtt brokers connected")
+	}
+
+	if me.mode == ModeFailover {
+		return me.endpoints[active].Write(message)
+	}
+
+	// fanout, fails only if none of the brokers received the message
+	var lastErr error
+	delivered := false
+	for index, endpoint := range me.endpoints {
+		if !connected[index] {
+			continue
+		}
+		if err := endpoint.Write(message); err != nil {
+			me.logger.Error("error on publishing to a broker", zap.String("adapterName", me.ID), zap.String("broker", endpoint.Config.Broker), zap.Error(err))
+			lastErr = err
+			continue
+		}
+		delivered = true
+	}
+	if !delivered {
+		return lastErr
+	}
+	return nil
+}
+
+// Close all the broker connections
+func (me *MultiEndpoint) Close() error {
+	me.mutex.Lock()
+	me.started = false
+	me.mutex.Unlock()
+
+	for _, endpoint := range me.endpoints {
+		_ = endpoint.Close()
+	}
+	return nil
+}
+
+// deduplicator drops the copies of a message received from the other brokers within the window.
+// the same message received again from the same broker is a new message
+type deduplicator struct {
+	mutex   sync.Mutex
+	window  time.Duration
+	entries *list.List // time ordered, oldest first
+	seen    map[[sha256.Size]byte][]*dedupEntry
+}
+
+// dedupEntry is a delivered message and the brokers received it
+type dedupEntry struct {
+	key        [sha256.Size]byte
+	receivedAt time.Time
+	brokers    map[int]bool
+}
+
+func newDeduplicator(window time.Duration) *deduplicator {
+	return &deduplicator{
+		window:  window,
+		entries: list.New(),
+		seen:    make(map[[sha256.Size]byte][]*dedupEntry),
+	}
+}
+
+// IsDuplicate returns true, if the same topic and payload received from another broker within the window
+// and not yet received from this broker
+func (d *deduplicator) IsDuplicate(broker int, topic string, payload []byte) bool {
+	hash := sha256.New()
+	hash.Write([]byte(topic))
+	hash.Write([]byte{0})
+	hash.Write(payload)
+	var key [sha256.Size]byte
+	copy(key[:], hash.Sum(nil))
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := time.Now()
+	d.removeExpired(now)
+
+	for _, entry := range d.seen[key] {
+		if !entry.brokers[broker] {
+			entry.brokers[broker] = true
+			return true
+		}
+	}
+	entry := &dedupEntry{key: key, receivedAt: now, brokers: map[int]bool{broker: true}}
+	d.entries.PushBack(entry)
+	d.seen[key] = append(d.seen[key], entry)
+	return false
+}
+
+// removes the entries older than the window, the caller should hold the lock
+func (d *deduplicator) removeExpired(now time.Time) {
+	for element := d.entries.Front(); element != nil; element = d.entries.Front() {
+		entry := element.Value.(*dedupEntry)
+		if now.Sub(entry.receivedAt) <= d.window {
+			return
+		}
+		d.entries.Remove(element)
+		// entries of a key are in the same order, the expired entry is the first one
+		if remaining := d.seen[entry.key][1:]; len(remaining) > 0 {
+			d.seen[entry.key] = remaining
+		} else {
+			delete(d.seen, entry.key)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package mqtt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mycontroller-org/server/v2/pkg/types/cmap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToBrokerConfigs(t *testing.T) {
+	config := cmap.CustomMap{
+		"mode":      "failover",
+		"subscribe": "in/#",
+		"publish":   "out",
+		"username":  "common",
+		"brokers": []interface{}{
+			map[string]interface{}{"broker": "tcp://primary:1883", "username": "primary"},
+			"tcp://standby:1883",
+		},
+	}
+
+	brokerConfigs, err := toBrokerConfigs(config)
+	assert.NoError(t, err)
+	assert.Equal(t, []cmap.CustomMap{
+		{"broker": "tcp://primary:1883", "username": "primary", "subscribe": "in/#", "publish": "out"},
+		{"broker": "tcp://standby:1883", "username": "common", "subscribe": "in/#", "publish": "out"},
+	}, brokerConfigs)
+
+	_, err = toBrokerConfigs(cmap.CustomMap{"brokers": []interface{}{}})
+	assert.Error(t, err)
+
+	_, err = toBrokerConfigs(cmap.CustomMap{"brokers": []interface{}{1}})
+	assert.Error(t, err)
+}
+
+func TestDeduplicator(t *testing.T) {
+	dedup := newDeduplicator(50 * time.Millisecond)
+
+	assert.False(t, dedup.IsDuplicate(0, "in/1", []byte("hello")))
+	assert.True(t, dedup.IsDuplicate(1, "in/1", []byte("hello")))
+	assert.False(t, dedup.IsDuplicate(1, "in/2", []byte("hello")))
+	assert.False(t, dedup.IsDuplicate(1, "in/1", []byte("world")))
+
+	// same payload legitimately repeated, each copy is delivered once
+	assert.False(t, dedup.IsDuplicate(0, "in/1", []byte("hello")))
+	assert.True(t, dedup.IsDuplicate(1, "in/1", []byte("hello")))
+	assert.False(t, dedup.IsDuplicate(1, "in/1", []byte("hello")))
+	assert.True(t, dedup.IsDuplicate(0, "in/1", []byte("hello")))
+
+	time.Sleep(60 * time.Millisecond)
+	assert.False(t, dedup.IsDuplicate(1, "in/1", []byte("hello")))
+	assert.Equal(t, 1, dedup.entries.Len())
+	assert.Len(t, dedup.seen, 1)
+}
@@ -24,6 +24,12 @@ func Register(name string, fn CreatorFn) {
 	creators[name] = fn
 }
 
+// IsRegistered returns true, if the device plugin is available
+func IsRegistered(name string) bool {
+	_, found := creators[name]
+	return found
+}
+
 func Create(ctx context.Context, name, ID string, config cmap.CustomMap, rxFunc func(msg *model.Message), statusFunc func(state *model.State)) (p deviceType.Plugin, err error) {
 	if fn, ok := creators[name]; ok {
 		p, err = fn(ctx, ID, config, rxFunc, statusFunc)
@@ -4,10 +4,12 @@ import (
 	"github.com/mycontroller-org/2mqtt/plugin/device/ethernet"
 	httpDevice "github.com/mycontroller-org/2mqtt/plugin/device/http"
 	"github.com/mycontroller-org/2mqtt/plugin/device/serial"
+	"github.com/mycontroller-org/2mqtt/plugin/device/simulator"
 )
 
 func init() {
 	Register(serial.PluginSerial, serial.NewDevice)
 	Register(httpDevice.PluginHTTP, httpDevice.NewDevice)
 	Register(ethernet.PluginEthernet, ethernet.NewDevice)
+	Register(simulator.PluginSimulator, simulator.NewDevice)
 }
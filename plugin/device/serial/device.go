@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/mycontroller-org/2mqtt/pkg/types"
+	cfgTY "github.com/mycontroller-org/2mqtt/pkg/types/config"
 	contextTY "github.com/mycontroller-org/2mqtt/pkg/types/context"
 	deviceType "github.com/mycontroller-org/2mqtt/plugin/device/types"
 	"github.com/mycontroller-org/server/v2/pkg/types/cmap"
@@ -64,7 +65,7 @@ func NewDevice(ctx context.Context, ID string, config cmap.CustomMap, rxFunc fun
 		cfg.MessageSplitter = &splitter
 	}
 
-	logger.Debug("source device config", zap.String("id", ID), zap.Any("config", cfg))
+	logger.Debug("source device config", zap.String("id", ID), zap.Any("config", cfgTY.Mask(cfg)))
 
 	serCfg := &ser.Config{Name: cfg.Port, Baud: cfg.BaudRate}
 
@@ -0,0 +1,296 @@
+package simulator
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mycontroller-org/2mqtt/pkg/types"
+	cfgTY "github.com/mycontroller-org/2mqtt/pkg/types/config"
+	contextTY "github.com/mycontroller-org/2mqtt/pkg/types/context"
+	deviceType "github.com/mycontroller-org/2mqtt/plugin/device/types"
+	"github.com/mycontroller-org/server/v2/pkg/types/cmap"
+	"github.com/mycontroller-org/server/v2/pkg/utils"
+	js "github.com/mycontroller-org/server/v2/pkg/utils/javascript"
+	"go.uber.org/zap"
+)
+
+// Constants in simulator device
+const (
+	PluginSimulator = "simulator"
+
+	intervalDefault   = time.Second * 10
+	scriptTimeout     = time.Second * 2
+	maxWrittenRecords = 1000
+	outgoingQueueSize = 100
+
+	// variables supplied to the script, script should set "result" with a string or a list of strings
+	scriptKeyCounter   = "counter"
+	scriptKeyTimestamp = "timestamp"
+)
+
+// Config details
+type Config struct {
+	Interval   string  `yaml:"interval"`
+	Script     string  `yaml:"script"`
+	Fixture    string  `yaml:"fixture"`
+	Loop       bool    `yaml:"loop"`
+	Echo       bool    `yaml:"echo"`
+	Replies    []Reply `yaml:"replies"`
+	RecordFile string  `yaml:"record_file"`
+}
+
+// Reply is a canned reply, sent when a written message matches the regex.
+// response can refer the regex groups, example: "$1"
+type Reply struct {
+	Match    string `yaml:"match"`
+	Response string `yaml:"response"`
+	Delay    string `yaml:"delay"`
+}
+
+type reply struct {
+	match    *regexp.Regexp
+	response string
+	delay    time.Duration
+}
+
+// Endpoint data
+type Endpoint struct {
+	logger         *zap.Logger
+	ID             string
+	Config         Config
+	receiveMsgFunc func(rm *types.Message)
+	statusFunc     func(state *types.State)
+	replies        []reply
+	fixture        []string
+	recordFile     *os.File
+	outgoing       chan *types.Message
+	done           chan struct{}
+	closeOnce      sync.Once
+	mutex          sync.Mutex
+	written        []*types.Message
+}
+
+// NewDevice simulator driver
+func NewDevice(ctx context.Context, ID string, config cmap.CustomMap, rxFunc func(msg *types.Message), statusFunc func(state *types.State)) (deviceType.Plugin, error) {
+	logger, err := contextTY.LoggerFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	err = utils.MapToStruct(utils.TagNameYaml, config, &cfg)
+	if err != nil {
+		logger.Error("error on converting map to struct", zap.Error(err))
+		return nil, err
+	}
+
+	logger.Debug("source device config", zap.String("id", ID), zap.Any("config", cfgTY.Mask(cfg)))
+
+	endpoint := &Endpoint{
+		logger:         logger.Named("simulator"),
+		ID:             ID,
+		Config:         cfg,
+		receiveMsgFunc: rxFunc,
+		statusFunc:     statusFunc,
+		outgoing:       make(chan *types.Message, outgoingQueueSize),
+		done:           make(chan struct{}),
+		written:        make([]*types.Message, 0),
+	}
+
+	for _, cfgReply := range cfg.Replies {
+		match, err := regexp.Compile(cfgReply.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reply match regex [%s]: %w", cfgReply.Match, err)
+		}
+		endpoint.replies = append(endpoint.replies, reply{match: match, response: cfgReply.Response, delay: utils.ToDuration(cfgReply.Delay, 0)})
+	}
+
+	if cfg.Fixture != "" {
+		fixture, err := readFixture(cfg.Fixture)
+		if err != nil {
+			return nil, err
+		}
+		endpoint.fixture = fixture
+	}
+
+	if cfg.RecordFile != "" {
+		recordFile, err := os.OpenFile(cfg.RecordFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		endpoint.recordFile = recordFile
+	}
+
+	go endpoint.dispatcher()
+	if cfg.Script != "" || len(endpoint.fixture) > 0 {
+		go endpoint.emitter(utils.ToDuration(cfg.Interval, intervalDefault))
+	}
+	return endpoint, nil
+}
+
+// reads the fixture file, a message per line, empty lines are ignored
+func readFixture(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+func (ep *Endpoint) Name() string {
+	return PluginSimulator
+}
+
+// Write records the message and sends the echo and the matching replies
+func (ep *Endpoint) Write(message *types.Message) error {
+	if message == nil || len(message.Data) == 0 {
+		return nil
+	}
+
+	ep.record(message)
+
+	if ep.Config.Echo {
+		ep.send(append([]byte{}, message.Data...))
+	}
+
+	for _, _reply := range ep.replies {
+		matchIndex := _reply.match.FindSubmatchIndex(message.Data)
+		if matchIndex == nil {
+			continue
+		}
+		response := _reply.match.Expand(nil, []byte(_reply.response), message.Data, matchIndex)
+		if _reply.delay > 0 {
+			time.AfterFunc(_reply.delay, func() { ep.send(response) })
+		} else {
+			ep.send(response)
+		}
+	}
+	return nil
+}
+
+// Written returns the messages written to the simulator, keeps the recent messages only
+func (ep *Endpoint) Written() []*types.Message {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+	return append([]*types.Message{}, ep.written...)
+}
+
+func (ep *Endpoint) record(message *types.Message) {
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+
+	ep.written = append(ep.written, message)
+	if len(ep.written) > maxWrittenRecords {
+		ep.written = ep.written[len(ep.written)-maxWrittenRecords:]
+	}
+
+	if ep.recordFile != nil {
+		if _, err := ep.recordFile.Write(append(append([]byte{}, message.Data...), '\n')); err != nil {
+			ep.logger.Error("error on writing to record file", zap.String("adapterID", ep.ID), zap.String("file", ep.Config.RecordFile), zap.Error(err))
+		}
+	}
+}
+
+// send posts a message to the dispatcher, dropped if the device closed
+func (ep *Endpoint) send(data []byte) {
+	select {
+	case <-ep.done:
+	case ep.outgoing <- types.NewMessage(data):
+	}
+}
+
+// dispatcher delivers the messages in order
+func (ep *Endpoint) dispatcher() {
+	for {
+		select {
+		case <-ep.done:
+			return
+		case message := <-ep.outgoing:
+			ep.receiveMsgFunc(message)
+		}
+	}
+}
+
+// emitter emits the messages from the script or the fixture on every interval
+func (ep *Endpoint) emitter(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	counter := int64(0)
+	for {
+		select {
+		case <-ep.done:
+			return
+		case <-ticker.C:
+			if ep.Config.Script != "" {
+				ep.emitFromScript(counter)
+			} else {
+				if !ep.Config.Loop && counter >= int64(len(ep.fixture)) {
+					ep.logger.Info("fixture completed", zap.String("adapterID", ep.ID), zap.String("fixture", ep.Config.Fixture))
+					return
+				}
+				ep.send([]byte(ep.fixture[counter%int64(len(ep.fixture))]))
+			}
+			counter++
+		}
+	}
+}
+
+func (ep *Endpoint) emitFromScript(counter int64) {
+	variables := map[string]interface{}{
+		scriptKeyCounter:   counter,
+		scriptKeyTimestamp: time.Now().UnixMilli(),
+	}
+	timeout := scriptTimeout
+	result, err := js.Execute(ep.logger, ep.Config.Script, variables, &timeout)
+	if err != nil {
+		ep.logger.Error("error on executing simulator script", zap.String("adapterID", ep.ID), zap.Error(err))
+		return
+	}
+	switch typedResult := result.(type) {
+	case nil:
+	case []interface{}:
+		for _, item := range typedResult {
+			ep.send([]byte(fmt.Sprintf("%v", item)))
+		}
+	case string:
+		if typedResult != "" {
+			ep.send([]byte(typedResult))
+		}
+	default:
+		ep.send([]byte(fmt.Sprintf("%v", typedResult)))
+	}
+}
+
+// Close stops the emitter
+func (ep *Endpoint) Close() error {
+	ep.closeOnce.Do(func() {
+		close(ep.done)
+		ep.mutex.Lock()
+		defer ep.mutex.Unlock()
+		if ep.recordFile != nil {
+			if err := ep.recordFile.Close(); err != nil {
+				ep.logger.Error("error on closing record file", zap.String("adapterID", ep.ID), zap.String("file", ep.Config.RecordFile), zap.Error(err))
+			}
+			ep.recordFile = nil
+		}
+	})
+	return nil
+}
@@ -0,0 +1,79 @@
+package simulator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mycontroller-org/2mqtt/pkg/types"
+	contextTY "github.com/mycontroller-org/2mqtt/pkg/types/context"
+	"github.com/mycontroller-org/server/v2/pkg/types/cmap"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type receiver struct {
+	mutex    sync.Mutex
+	messages []string
+}
+
+func (r *receiver) onMessage(message *types.Message) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.messages = append(r.messages, string(message.Data))
+}
+
+func (r *receiver) received() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string{}, r.messages...)
+}
+
+func TestFixture(t *testing.T) {
+	fixture := filepath.Join(t.TempDir(), "fixture.txt")
+	assert.NoError(t, os.WriteFile(fixture, []byte("0;255;3;0;14;Gateway startup complete.\n\n1;1;1;0;0;23.5\n"), 0644))
+
+	ctx := contextTY.LoggerWithContext(context.Background(), zap.NewNop())
+	r := &receiver{}
+	device, err := NewDevice(ctx, "test", cmap.CustomMap{"interval": "10ms", "fixture": fixture}, r.onMessage, func(state *types.State) {})
+	assert.NoError(t, err)
+	defer device.Close()
+
+	expected := []string{"0;255;3;0;14;Gateway startup complete.", "1;1;1;0;0;23.5"}
+	assert.Eventually(t, func() bool { return len(r.received()) == len(expected) }, time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, expected, r.received())
+}
+
+func TestEchoAndReplies(t *testing.T) {
+	recordFile := filepath.Join(t.TempDir(), "written.txt")
+	config := cmap.CustomMap{
+		"echo":        true,
+		"record_file": recordFile,
+		"replies": []interface{}{
+			map[string]interface{}{"match": `^(\d+);255;3;0;2;$`, "response": "$1;255;3;0;2;2.3.2"},
+		},
+	}
+
+	ctx := contextTY.LoggerWithContext(context.Background(), zap.NewNop())
+	r := &receiver{}
+	device, err := NewDevice(ctx, "test", config, r.onMessage, func(state *types.State) {})
+	assert.NoError(t, err)
+
+	assert.NoError(t, device.Write(types.NewMessage([]byte("0;255;3;0;2;"))))
+	assert.NoError(t, device.Write(types.NewMessage([]byte("hello"))))
+
+	assert.Eventually(t, func() bool { return len(r.received()) == 3 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"0;255;3;0;2;", "0;255;3;0;2;2.3.2", "hello"}, r.received())
+
+	simulator := device.(*Endpoint)
+	assert.Len(t, simulator.Written(), 2)
+
+	assert.NoError(t, device.Close())
+	data, err := os.ReadFile(recordFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "0;255;3;0;2;\nhello\n", string(data))
+}
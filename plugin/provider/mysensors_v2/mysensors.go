@@ -17,7 +17,7 @@ func NewProvider(ctx context.Context, config cmap.CustomMap, formatter cfgTY.For
 	name := config.GetString(types.KeyName)
 
 	switch sourceType {
-	case types.DeviceSerial, types.DeviceEthernet:
+	case types.DeviceSerial, types.DeviceEthernet, types.DeviceSimulator:
 		config.Set(types.KeyMessageSplitter, MessageSplitter, nil)
 		return New(ctx, name)
 
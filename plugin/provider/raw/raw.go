@@ -17,7 +17,7 @@ func NewProvider(ctx context.Context, cfg cmap.CustomMap, formatter cfgTY.Format
 	name := cfg.GetString(types.KeyName)
 
 	switch sourceType {
-	case types.DeviceSerial, types.DeviceEthernet, types.DeviceHTTP:
+	case types.DeviceSerial, types.DeviceEthernet, types.DeviceHTTP, types.DeviceSimulator:
 		return New(ctx, name, formatter)
 
 	default: